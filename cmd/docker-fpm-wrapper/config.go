@@ -2,55 +2,25 @@ package main
 
 import (
 	"strings"
-	"time"
 
 	"github.com/FZambia/viper-lite"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/spf13/pflag"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/wrapper"
 )
 
 type Config struct {
-	LogLevel   string `mapstructure:"log-level"`
-	LogEncoder string `mapstructure:"log-encoder"`
-
-	FpmPath       string `mapstructure:"fpm"`
-	FpmConfigPath string `mapstructure:"fpm-config"`
-
-	FpmNoErrlogProxy  bool `mapstructure:"fpm-no-errlog"`
-	FpmNoSlowlogProxy bool `mapstructure:"fpm-no-slowlog"`
-
-	// Logging proxy section
-	WrapperPipe    string `mapstructure:"wrapper-pipe"`
-	WrapperSocket  string `mapstructure:"wrapper-socket"`
-	LineBufferSize int    `mapstructure:"line-buffer-size"`
-
-	//
-	Listen      string `mapstructure:"listen"`
-	MetricsPath string `mapstructure:"metrics-path"`
+	wrapper.Options `mapstructure:",squash"`
 
-	ShutdownDelay time.Duration `mapstructure:"shutdown-delay"`
+	// parse-errlog and parse-slowlog section
+	Follow bool `mapstructure:"follow"`
 }
 
 func parseCommandLineFlags() {
-	pflag.String("log-level", "-1", "Log level. -1 debug ")
-	pflag.String("log-encoder", "auto", "Internal logging encoder")
-
-	pflag.StringP("fpm", "f", "", "path to php-fpm")
-	pflag.StringP("fpm-config", "c", "/etc/php/php-fpm.conf", "path to php-fpm config file")
-
-	pflag.Bool("fpm-no-errlog", false, "Disable php-fpm errlog parsing and proxy")
-	pflag.Bool("fpm-no-slowlog", false, "Disable php-fpm slowlog parsing and proxy")
-
-	// Logging proxy section
-	pflag.StringP("wrapper-pipe", "p", "/tmp/fpm-wrapper-pipe", "path to logging pipe, set '' to disable")
-	pflag.StringP("wrapper-socket", "s", "/tmp/fpm-wrapper.sock", "path to logging socket, set null to disable")
-	pflag.Uint("line-buffer-size", 16*1024, "Max log line size (in bytes)")
-
-	// Prom section
-	pflag.String("listen", ":8080", "prometheus statistic addr")
-	pflag.String("metrics-path", "/metrics", "prometheus statistic path")
+	wrapper.AddFlags(pflag.CommandLine)
 
-	pflag.Duration("shutdown-delay", 500*time.Millisecond, "Delay before process shutdown")
+	pflag.Bool("follow", false, "parse-errlog and parse-slowlog: wait for new lines and follow the file across rotations")
 
 	pflag.Parse()
 }
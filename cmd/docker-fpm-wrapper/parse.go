@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/pflag"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/follow"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/wrapper"
+)
+
+const (
+	parseCommandErrlog  = "parse-errlog"
+	parseCommandSlowlog = "parse-slowlog"
+)
+
+// findParseCommand returns the parse command and removes it from os.Args, so the rest is parsed as usual flags
+func findParseCommand() (string, bool) {
+	if len(os.Args) < 2 {
+		return "", false
+	}
+
+	switch command := os.Args[1]; command {
+	case parseCommandErrlog, parseCommandSlowlog:
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+
+		return command, true
+	default:
+		return "", false
+	}
+}
+
+// openParseInput opens the file, stdin for "-" or empty path. The file is followed across rotations when follow is set.
+func openParseInput(ctx context.Context, path string, followFile bool) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	if followFile {
+		return follow.Open(ctx, path, follow.DefaultPollInterval)
+	}
+
+	return os.Open(path)
+}
+
+// runParseCommand parses the existing php-fpm error log or slowlog file given as the first argument and writes
+// records to stdout with the configured encoder, json by default. It returns the exit code.
+func runParseCommand(command string) int {
+	cfg, err := createConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't create app config: %v\n", err)
+		return 1
+	}
+
+	if cfg.LogEncoder == "auto" {
+		cfg.LogEncoder = "json"
+	}
+
+	w, err := wrapper.New(cfg.Options, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't create wrapper: %v\n", err)
+		return 1
+	}
+	defer w.Sync()
+
+	ctx := context.Background()
+	if cfg.Follow {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// metrics and slowlog endpoints of the followed file
+		listener, err := net.Listen("tcp", cfg.Listen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't listen %s: %v\n", cfg.Listen, err)
+			return 1
+		}
+
+		go func() {
+			_ = http.Serve(listener, w)
+		}()
+	}
+
+	in, err := openParseInput(ctx, pflag.Arg(0), cfg.Follow)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't open input: %v\n", err)
+		return 1
+	}
+	defer in.Close()
+
+	switch command {
+	case parseCommandErrlog:
+		err = w.ParseErrlog(ctx, in)
+	case parseCommandSlowlog:
+		err = w.ParseSlowlog(ctx, in, cfg.Follow)
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		fmt.Fprintf(os.Stderr, "Can't parse %s: %v\n", pflag.Arg(0), err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,88 @@
+<?php
+
+declare(strict_types=1);
+
+namespace FpmWrapper\Monolog;
+
+use Monolog\Handler\AbstractProcessingHandler;
+use Monolog\Level;
+use Monolog\LogRecord;
+
+/**
+ * Sends structured records to docker-fpm-wrapper over FPM_WRAPPER_SOCK
+ * using the json framed protocol (one json record per line).
+ *
+ * Requires monolog/monolog ^3.0.
+ *
+ * Usage:
+ *   $logger->pushHandler(new FpmWrapperHandler());
+ */
+final class FpmWrapperHandler extends AbstractProcessingHandler
+{
+    private const HANDSHAKE = "FPM-WRAPPER/1 json\n";
+
+    /** @var resource|null */
+    private $socket;
+
+    private string $address;
+
+    public function __construct(
+        ?string $address = null,
+        private readonly ?string $requestId = null,
+        int|string|Level $level = Level::Debug,
+        bool $bubble = true,
+    ) {
+        parent::__construct($level, $bubble);
+
+        $this->address = $address ?? (string) getenv('FPM_WRAPPER_SOCK');
+    }
+
+    protected function write(LogRecord $record): void
+    {
+        $socket = $this->connect();
+        if ($socket === null) {
+            return;
+        }
+
+        $payload = json_encode([
+            'time' => $record->datetime->format(DATE_RFC3339_EXTENDED),
+            'level' => $record->level->toPsrLogLevel(),
+            'channel' => $record->channel,
+            'request_id' => $this->requestId ?? ($_SERVER['HTTP_X_REQUEST_ID'] ?? ''),
+            'message' => $record->message,
+            'context' => (object) $record->context,
+            'extra' => (object) $record->extra,
+        ], JSON_UNESCAPED_SLASHES | JSON_UNESCAPED_UNICODE | JSON_INVALID_UTF8_SUBSTITUTE | JSON_PARTIAL_OUTPUT_ON_ERROR);
+
+        if ($payload === false || @fwrite($socket, $payload . "\n") === false) {
+            @fclose($socket);
+            $this->socket = null;
+        }
+    }
+
+    /** @return resource|null */
+    private function connect()
+    {
+        if ($this->socket !== null) {
+            return $this->socket;
+        }
+
+        if ($this->address === '') {
+            return null;
+        }
+
+        $socket = @stream_socket_client($this->address, $errno, $errstr, 1.0, STREAM_CLIENT_CONNECT | STREAM_CLIENT_PERSISTENT);
+        if ($socket === false) {
+            return null;
+        }
+
+        // persistent sockets outlive the request, send handshake only for the fresh ones
+        if (ftell($socket) === 0 && @fwrite($socket, self::HANDSHAKE) === false) {
+            @fclose($socket);
+
+            return null;
+        }
+
+        return $this->socket = $socket;
+    }
+}
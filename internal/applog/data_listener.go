@@ -1,27 +1,72 @@
 package applog
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 
+	"go.uber.org/zap"
+
 	"github.com/code-tool/docker-fpm-wrapper/internal/breader"
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
 	"github.com/code-tool/docker-fpm-wrapper/pkg/line"
 )
 
+// SockDataListener forwards lines and framed records of socket connections. Errors of a single connection
+// are logged and close the connection, only listener failures are sent to errorChan.
 type SockDataListener struct {
+	log        *zap.Logger
 	socketPath string
 	listener   net.Listener
 	rPool      *breader.Pool
 
 	writer    io.Writer
+	recWriter *RecordWriter
+	enricher  *enrich.Enricher
 	errorChan chan error
 }
 
-func NewSockDataListener(sockPath string, rPool *breader.Pool, writer io.Writer, errorChan chan error) *SockDataListener {
-	return &SockDataListener{socketPath: sockPath, rPool: rPool, writer: writer, errorChan: errorChan}
+func NewSockDataListener(
+	log *zap.Logger,
+	sockPath string,
+	rPool *breader.Pool,
+	writer io.Writer,
+	recWriter *RecordWriter,
+	enricher *enrich.Enricher,
+	errorChan chan error,
+) *SockDataListener {
+	return &SockDataListener{
+		log:        log,
+		socketPath: sockPath,
+		rPool:      rPool,
+		writer:     writer,
+		recWriter:  recWriter,
+		enricher:   enricher,
+		errorChan:  errorChan,
+	}
+}
+
+func (l *SockDataListener) handleErr(err error, pid int) {
+	if err != io.EOF {
+		l.log.Warn("can't read log connection", zap.Int("peer_pid", pid), zap.Error(err))
+	}
+}
+
+func (l *SockDataListener) handleFramed(reader *bufio.Reader, framing Framing, pid int) {
+	for {
+		payload, err := readFrame(reader, framing)
+		if len(payload) > 0 {
+			l.recWriter.WriteJSON(payload, l.enricher.PeerFields(pid)...)
+		}
+
+		if err != nil {
+			l.handleErr(err, pid)
+			return
+		}
+	}
 }
 
 func (l *SockDataListener) handleConnection(conn net.Conn) {
@@ -30,21 +75,25 @@ func (l *SockDataListener) handleConnection(conn net.Conn) {
 	reader := l.rPool.Get(conn)
 	defer l.rPool.Put(reader)
 
+	pid := peerPid(conn)
+
+	buf, err := line.ReadOne(reader, true)
+	if framing, ok := parseHandshake(buf); ok && err == nil {
+		l.handleFramed(reader, framing, pid)
+		return
+	}
+
 	for {
-		buf, err := line.ReadOne(reader, true)
 		if len(buf) > 0 {
-			_, _ = l.writer.Write(normalizeLine(buf))
-		}
-
-		if err == nil {
-			continue
+			_, _ = l.writer.Write(l.enricher.AppendJSON(normalizeLine(buf), pid))
 		}
 
-		if err != io.EOF {
-			l.errorChan <- err
+		if err != nil {
+			l.handleErr(err, pid)
+			break
 		}
 
-		break
+		buf, err = line.ReadOne(reader, true)
 	}
 }
 
@@ -78,6 +127,10 @@ func (l *SockDataListener) initSocket() error {
 func (l *SockDataListener) acceptConnections() {
 	for {
 		conn, err := l.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
 		if err != nil {
 			l.errorChan <- err
 			return
@@ -0,0 +1,81 @@
+package applog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/breader"
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
+)
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func sendToSocket(t *testing.T, path string, data []byte) {
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+
+	_, err = conn.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
+
+func TestSockDataListener_TruncatedFrame(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "wrapper.sock")
+	core, logs := observer.New(zapcore.WarnLevel)
+	out := &lockedBuffer{}
+	errCh := make(chan error, 1)
+
+	l := NewSockDataListener(
+		zap.New(core), sockPath, breader.NewPool(1024), out, NewRecordWriter(zap.NewNop()), enrich.NewNopEnricher(), errCh,
+	)
+	require.NoError(t, l.Start())
+	defer l.Stop()
+
+	// the worker was killed in the middle of the frame
+	truncated := []byte(HandshakePrefix + "length\n")
+	truncated = binary.BigEndian.AppendUint32(truncated, 100)
+	truncated = append(truncated, `{"mess`...)
+	sendToSocket(t, sockPath, truncated)
+
+	assert.Eventually(t, func() bool { return logs.Len() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, io.ErrUnexpectedEOF.Error(), logs.All()[0].ContextMap()["error"])
+
+	sendToSocket(t, sockPath, []byte("plain line\n"))
+	assert.Eventually(t, func() bool { return out.String() == "plain line\n" }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, logs.Len())
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected listener error: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,88 @@
+package applog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/line"
+)
+
+type Framing int
+
+const (
+	// FramingPlain is the default newline-delimited raw mode, lines are forwarded as is
+	FramingPlain Framing = iota
+	// FramingJSON carries one json encoded Record per line
+	FramingJSON
+	// FramingLength carries json encoded Records prefixed with a 4 byte big-endian length
+	FramingLength
+)
+
+// HandshakePrefix starts the first line of a connection that wants a framed protocol,
+// e.g. "FPM-WRAPPER/1 json\n" or "FPM-WRAPPER/1 length\n".
+const HandshakePrefix = "FPM-WRAPPER/1 "
+
+const frameHeaderSize = 4
+
+func parseHandshake(buf []byte) (Framing, bool) {
+	if !bytes.HasPrefix(buf, []byte(HandshakePrefix)) {
+		return FramingPlain, false
+	}
+
+	switch string(bytes.TrimRight(buf[len(HandshakePrefix):], "\r\n")) {
+	case "json":
+		return FramingJSON, true
+	case "length":
+		return FramingLength, true
+	default:
+		return FramingPlain, false
+	}
+}
+
+// readFrame returns next frame payload. Returned slice is valid only until the next read from r.
+// Frames which don't fit into the reader buffer are skipped.
+func readFrame(r *bufio.Reader, framing Framing) ([]byte, error) {
+	if framing != FramingLength {
+		return line.ReadOne(r, true)
+	}
+
+	for {
+		header, err := r.Peek(frameHeaderSize)
+		if err != nil {
+			if len(header) > 0 && err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		size := int(binary.BigEndian.Uint32(header))
+		if _, err = r.Discard(frameHeaderSize); err != nil {
+			return nil, err
+		}
+
+		if size > r.Size() {
+			// frame is too long
+			if _, err = r.Discard(size); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		payload, err := r.Peek(size)
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		_, err = r.Discard(size)
+
+		return payload, err
+	}
+}
@@ -0,0 +1,62 @@
+package applog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lengthFrame(payload string) []byte {
+	buf := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+
+	return append(buf, payload...)
+}
+
+func TestParseHandshake(t *testing.T) {
+	framing, ok := parseHandshake([]byte("FPM-WRAPPER/1 json\n"))
+	assert.True(t, ok)
+	assert.Equal(t, FramingJSON, framing)
+
+	framing, ok = parseHandshake([]byte("FPM-WRAPPER/1 length\r\n"))
+	assert.True(t, ok)
+	assert.Equal(t, FramingLength, framing)
+
+	_, ok = parseHandshake([]byte("FPM-WRAPPER/1 xml\n"))
+	assert.False(t, ok)
+
+	_, ok = parseHandshake([]byte("plain log line\n"))
+	assert.False(t, ok)
+}
+
+func TestReadFrameLength(t *testing.T) {
+	var in bytes.Buffer
+	in.Write(lengthFrame("{\"message\":\"multi\\nline\"}"))
+	in.Write(lengthFrame("this frame is much longer than reader buffer"))
+	in.Write(lengthFrame("{}"))
+
+	r := bufio.NewReaderSize(&in, 32)
+
+	payload, err := readFrame(r, FramingLength)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"message\":\"multi\\nline\"}", string(payload))
+
+	payload, err = readFrame(r, FramingLength)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(payload))
+
+	_, err = readFrame(r, FramingLength)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadFrameLengthTruncated(t *testing.T) {
+	frame := lengthFrame("{\"message\":\"test\"}")
+	r := bufio.NewReader(bytes.NewReader(frame[:len(frame)-3]))
+
+	_, err := readFrame(r, FramingLength)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -0,0 +1,33 @@
+package applog
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerPid returns pid of the process on the other side of unix socket connection or 0
+func peerPid(conn net.Conn) int {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0
+	}
+
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+
+	var (
+		cred    *unix.Ucred
+		credErr error
+	)
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil || credErr != nil {
+		return 0
+	}
+
+	return int(cred.Pid)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package applog
+
+import (
+	"net"
+)
+
+// peerPid is supported only on linux
+func peerPid(_ net.Conn) int {
+	return 0
+}
@@ -7,16 +7,18 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
 	"github.com/code-tool/docker-fpm-wrapper/pkg/line"
 )
 
 type PipeProxy struct {
-	log    *zap.Logger
-	writer io.Writer
+	log      *zap.Logger
+	writer   io.Writer
+	enricher *enrich.Enricher
 }
 
-func NewPipeProxy(log *zap.Logger, writer io.Writer) *PipeProxy {
-	return &PipeProxy{log: log, writer: writer}
+func NewPipeProxy(log *zap.Logger, writer io.Writer, enricher *enrich.Enricher) *PipeProxy {
+	return &PipeProxy{log: log, writer: writer, enricher: enricher}
 }
 
 func (p *PipeProxy) Proxy(r io.Reader) {
@@ -25,7 +27,7 @@ func (p *PipeProxy) Proxy(r io.Reader) {
 	for {
 		buf, err := line.ReadOne(bufioReader, true)
 		if len(buf) > 0 {
-			_, _ = p.writer.Write(normalizeLine(buf))
+			_, _ = p.writer.Write(p.enricher.AppendJSON(normalizeLine(buf), 0))
 		}
 
 		if err == nil {
@@ -0,0 +1,87 @@
+package applog
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultChannel = "app"
+
+// Record is a single structured application log message received over a framed connection.
+type Record struct {
+	Time      time.Time      `json:"time"`
+	Level     string         `json:"level"`
+	Channel   string         `json:"channel"`
+	RequestID string         `json:"request_id"`
+	Message   string         `json:"message"`
+	Context   map[string]any `json:"context"`
+	Extra     map[string]any `json:"extra"`
+}
+
+// MapRecordLevel maps psr-3 (monolog) level names to zap levels
+func MapRecordLevel(level string) zapcore.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return zap.DebugLevel
+	case "info", "notice":
+		return zap.InfoLevel
+	case "warning", "warn":
+		return zap.WarnLevel
+	case "error", "critical", "alert", "emergency":
+		return zap.ErrorLevel
+	default:
+		return zap.InfoLevel
+	}
+}
+
+type RecordWriter struct {
+	log *zap.Logger
+}
+
+func NewRecordWriter(log *zap.Logger) *RecordWriter {
+	return &RecordWriter{log: log}
+}
+
+func (w *RecordWriter) Write(rec *Record, extra ...zap.Field) {
+	channel := rec.Channel
+	if channel == "" {
+		channel = defaultChannel
+	}
+
+	ce := w.log.Named(channel).Check(MapRecordLevel(rec.Level), rec.Message)
+	if ce == nil {
+		return
+	}
+
+	if !rec.Time.IsZero() {
+		ce.Time = rec.Time
+	}
+
+	fields := make([]zap.Field, 0, 3+len(extra))
+	if rec.RequestID != "" {
+		fields = append(fields, zap.String("request_id", rec.RequestID))
+	}
+	if len(rec.Context) > 0 {
+		fields = append(fields, zap.Any("context", rec.Context))
+	}
+	if len(rec.Extra) > 0 {
+		fields = append(fields, zap.Any("extra", rec.Extra))
+	}
+	fields = append(fields, extra...)
+
+	ce.Write(fields...)
+}
+
+func (w *RecordWriter) WriteJSON(payload []byte, extra ...zap.Field) {
+	rec := Record{}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		w.log.Warn("can't decode app log record", zap.Error(err))
+		return
+	}
+
+	w.Write(&rec, extra...)
+}
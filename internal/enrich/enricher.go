@@ -0,0 +1,198 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+// ProcessFinder resolves php-fpm worker pid into its pool and current request. It's called for every forwarded
+// record, so it must not block on the status page.
+type ProcessFinder interface {
+	FindProcess(pid int) (string, phpfpm.ProcessStatus, bool)
+}
+
+type field struct {
+	key   string
+	value any
+}
+
+type Enricher struct {
+	enabled    bool
+	static     []field
+	staticJSON []byte
+	// pool is set when records come from the pool own socket or pipe
+	pool string
+
+	processes ProcessFinder
+}
+
+// NewEnricher creates enricher with static fields taken from env.
+// Every envFields entry has form "field=ENV_NAME", fields with empty env value are skipped.
+// processes may be nil, in that case peer fields contain only pid.
+func NewEnricher(envFields []string, processes ProcessFinder) *Enricher {
+	e := &Enricher{enabled: true, processes: processes}
+
+	for _, ef := range envFields {
+		key, envName, ok := strings.Cut(ef, "=")
+		if !ok {
+			continue
+		}
+
+		if val := os.Getenv(strings.TrimSpace(envName)); val != "" {
+			e.static = append(e.static, field{key: strings.TrimSpace(key), value: val})
+		}
+	}
+
+	e.staticJSON = appendJSONFields(nil, e.static)
+
+	return e
+}
+
+// NewNopEnricher creates enricher which doesn't add anything
+func NewNopEnricher() *Enricher {
+	return &Enricher{}
+}
+
+// ForPool returns enricher for records received on the pool own socket or pipe.
+// The pool field is added to every record even when enrichment is disabled.
+func (e *Enricher) ForPool(poolName string) *Enricher {
+	pe := *e
+	pe.pool = poolName
+	pe.static = append(append([]field(nil), e.static...), field{key: "pool", value: poolName})
+	pe.staticJSON = appendJSONFields(nil, pe.static)
+
+	return &pe
+}
+
+func toZapFields(dst []zap.Field, fields []field) []zap.Field {
+	for _, f := range fields {
+		dst = append(dst, zap.Any(f.key, f.value))
+	}
+
+	return dst
+}
+
+func appendJSONFields(dst []byte, fields []field) []byte {
+	for _, f := range fields {
+		if len(dst) > 0 {
+			dst = append(dst, ',')
+		}
+
+		key, _ := json.Marshal(f.key)
+		dst = append(dst, key...)
+		dst = append(dst, ':')
+
+		val, err := json.Marshal(f.value)
+		if err != nil {
+			val = []byte("null")
+		}
+		dst = append(dst, val...)
+	}
+
+	return dst
+}
+
+// Fields returns static fields added to every record
+func (e *Enricher) Fields() []zap.Field {
+	return toZapFields(nil, e.static)
+}
+
+// PoolFields returns fields for the records emitted by the pool, e.g. slowlog
+func (e *Enricher) PoolFields(poolName string) []zap.Field {
+	if !e.enabled {
+		return nil
+	}
+
+	return []zap.Field{zap.String("pool", poolName)}
+}
+
+func (e *Enricher) peerFields(pid int) []field {
+	if !e.enabled || pid <= 0 {
+		return nil
+	}
+
+	result := []field{{key: "peer_pid", value: pid}}
+	if e.processes == nil {
+		return result
+	}
+
+	poolName, proc, ok := e.processes.FindProcess(pid)
+	if !ok {
+		return result
+	}
+
+	if e.pool == "" {
+		result = append(result, field{key: "pool", value: poolName})
+	}
+
+	return append(result,
+		field{key: "request_method", value: proc.RequestMethod},
+		field{key: "request_uri", value: proc.RequestURI},
+	)
+}
+
+// PeerFields returns fields describing the worker connected from pid and its current request
+func (e *Enricher) PeerFields(pid int) []zap.Field {
+	return toZapFields(nil, e.peerFields(pid))
+}
+
+// AppendJSON adds static and peer fields into the line when it contains a json object. Other lines of the pool
+// enricher are wrapped into a json object with the message key, so they get the pool field too,
+// the rest are returned as is.
+func (e *Enricher) AppendJSON(line []byte, pid int) []byte {
+	if len(e.staticJSON) == 0 && (!e.enabled || pid <= 0) {
+		return line
+	}
+
+	trimmed := bytes.TrimRight(line, "\r\n\t ")
+	if len(trimmed) < 2 || trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' {
+		if e.pool == "" || len(trimmed) == 0 {
+			return line
+		}
+
+		return e.wrapPlain(line, pid)
+	}
+
+	fragment := appendJSONFields(append([]byte(nil), e.staticJSON...), e.peerFields(pid))
+	if len(fragment) == 0 {
+		return line
+	}
+
+	closePos := len(trimmed) - 1
+	result := make([]byte, 0, len(line)+len(fragment)+1)
+	result = append(result, trimmed[:closePos]...)
+	if len(bytes.TrimSpace(trimmed[1:closePos])) > 0 {
+		result = append(result, ',')
+	}
+	result = append(result, fragment...)
+	result = append(result, line[closePos:]...)
+
+	return result
+}
+
+// wrapPlain returns {"message": line, fields...} with the line ending kept
+func (e *Enricher) wrapPlain(line []byte, pid int) []byte {
+	content := bytes.TrimRight(line, "\r\n")
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(string(content)); err != nil {
+		return line
+	}
+
+	result := make([]byte, 0, len(line)+len(e.staticJSON)+32)
+	result = append(result, `{"message":`...)
+	result = append(result, bytes.TrimRight(buf.Bytes(), "\n")...)
+	result = append(result, ',')
+	result = appendJSONFields(append(result, e.staticJSON...), e.peerFields(pid))
+	result = append(result, '}')
+
+	return append(result, line[len(content):]...)
+}
@@ -0,0 +1,69 @@
+package enrich
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+type processFinderStub map[int]phpfpm.ProcessStatus
+
+func (s processFinderStub) FindProcess(pid int) (string, phpfpm.ProcessStatus, bool) {
+	proc, ok := s[pid]
+
+	return "www", proc, ok
+}
+
+func TestEnricher_AppendJSON(t *testing.T) {
+	t.Setenv("TEST_POD_NAME", "app-6d4cf56db6-x2xqv")
+
+	e := NewEnricher([]string{"pod=TEST_POD_NAME", "namespace=TEST_UNSET_ENV", "broken"}, processFinderStub{
+		42: {Pid: 42, RequestMethod: "GET", RequestURI: "/index.php?id=1"},
+	})
+
+	assert.Equal(t,
+		`{"message":"test","pod":"app-6d4cf56db6-x2xqv"}`+"\n",
+		string(e.AppendJSON([]byte(`{"message":"test"}`+"\n"), 0)),
+	)
+	assert.Equal(t,
+		`{"pod":"app-6d4cf56db6-x2xqv","peer_pid":42,"pool":"www","request_method":"GET","request_uri":"/index.php?id=1"}`+"\n",
+		string(e.AppendJSON([]byte("{}\n"), 42)),
+	)
+	assert.Equal(t,
+		`{"a":1,"pod":"app-6d4cf56db6-x2xqv","peer_pid":7}`,
+		string(e.AppendJSON([]byte(`{"a":1}`), 7)),
+	)
+	assert.Equal(t, "plain line\n", string(e.AppendJSON([]byte("plain line\n"), 42)))
+}
+
+func TestNopEnricher(t *testing.T) {
+	e := NewNopEnricher()
+
+	assert.Equal(t, `{"a":1}`, string(e.AppendJSON([]byte(`{"a":1}`), 42)))
+	assert.Empty(t, e.Fields())
+	assert.Empty(t, e.PeerFields(42))
+	assert.Empty(t, e.PoolFields("www"))
+}
+
+func TestEnricher_ForPool(t *testing.T) {
+	e := NewEnricher(nil, processFinderStub{42: {Pid: 42, RequestMethod: "GET", RequestURI: "/"}}).ForPool("api")
+
+	assert.Equal(t,
+		`{"pool":"api","peer_pid":42,"request_method":"GET","request_uri":"/"}`,
+		string(e.AppendJSON([]byte(`{}`), 42)),
+	)
+
+	nop := NewNopEnricher().ForPool("api")
+	assert.Equal(t, `{"a":1,"pool":"api"}`, string(nop.AppendJSON([]byte(`{"a":1}`), 42)))
+	assert.Len(t, nop.Fields(), 1)
+
+	assert.Equal(t,
+		`{"message":"plain \"<b>\" line","pool":"api","peer_pid":42,"request_method":"GET","request_uri":"/"}`+"\r\n",
+		string(e.AppendJSON([]byte("plain \"<b>\" line\r\n"), 42)),
+	)
+	assert.Equal(t, `{"message":"plain","pool":"api"}`+"\n", string(nop.AppendJSON([]byte("plain\n"), 0)))
+	assert.Equal(t, "\n", string(nop.AppendJSON([]byte("\n"), 0)))
+	assert.Equal(t, "plain\n", string(NewEnricher(nil, nil).AppendJSON([]byte("plain\n"), 42)))
+}
@@ -0,0 +1,94 @@
+package follow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+const DefaultPollInterval = 250 * time.Millisecond
+
+// Reader reads the file like tail -F: at the end of file it waits for new data instead of returning io.EOF.
+// When the path is replaced (logrotate create mode) the new file is read from the start, when the file is
+// truncated (copytruncate mode) it's read from the start again. Read returns io.EOF when ctx is done.
+type Reader struct {
+	ctx      context.Context
+	path     string
+	interval time.Duration
+
+	f      *os.File
+	offset int64
+}
+
+func Open(ctx context.Context, path string, interval time.Duration) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &Reader{ctx: ctx, path: path, interval: interval, f: f}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.f.Read(p)
+		r.offset += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		if err = r.checkRotation(); err != nil {
+			return 0, err
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// checkRotation switches to the new file at the path or rewinds the truncated file.
+// The old file is kept while the path is missing, it's read to the end before the switch.
+func (r *Reader) checkRotation() error {
+	current, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if current.Size() < r.offset {
+		r.offset, err = r.f.Seek(0, io.SeekStart)
+
+		return err
+	}
+
+	next, err := os.Stat(r.path)
+	if err != nil || os.SameFile(current, next) {
+		return nil
+	}
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil
+	}
+
+	_ = r.f.Close()
+	r.f, r.offset = f, 0
+
+	return nil
+}
+
+func (r *Reader) Close() error {
+	return r.f.Close()
+}
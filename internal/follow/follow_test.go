@@ -0,0 +1,73 @@
+package follow
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+}
+
+func readLine(t *testing.T, r *bufio.Reader) string {
+	lineCh := make(chan string, 1)
+	go func() {
+		line, _ := r.ReadString('\n')
+		lineCh <- line
+	}()
+
+	select {
+	case line := <-lineCh:
+		return line
+	case <-time.After(time.Second):
+		t.Fatal("line wasn't read")
+		return ""
+	}
+}
+
+func TestReaderFollowsRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "php-fpm.log")
+	appendFile(t, path, "first\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fr, err := Open(ctx, path, 5*time.Millisecond)
+	require.NoError(t, err)
+	defer fr.Close()
+
+	r := bufio.NewReader(fr)
+	assert.Equal(t, "first\n", readLine(t, r))
+
+	appendFile(t, path, "appended\n")
+	assert.Equal(t, "appended\n", readLine(t, r))
+
+	// logrotate create mode
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path+".1", "before rotation\n")
+	appendFile(t, path, "rotated\n")
+	assert.Equal(t, "before rotation\n", readLine(t, r))
+	assert.Equal(t, "rotated\n", readLine(t, r))
+
+	// logrotate copytruncate mode
+	require.NoError(t, os.Truncate(path, 0))
+	appendFile(t, path, "cut\n")
+	assert.Equal(t, "cut\n", readLine(t, r))
+
+	cancel()
+	_, err = r.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
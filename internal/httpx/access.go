@@ -0,0 +1,100 @@
+package httpx
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AccessControl allows requests from the allowed networks or with the bearer token.
+// Everything is allowed when neither networks nor token are configured.
+type AccessControl struct {
+	nets  []*net.IPNet
+	token string
+}
+
+func NewAccessControl(allow []string, token string) (*AccessControl, error) {
+	ac := &AccessControl{token: token}
+
+	for _, cidr := range allow {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse allowed network: %w", err)
+		}
+
+		ac.nets = append(ac.nets, ipNet)
+	}
+
+	return ac, nil
+}
+
+func (ac *AccessControl) allowedAddr(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range ac.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ac *AccessControl) allowedToken(authorization string) bool {
+	if ac.token == "" {
+		return false
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(ac.token)) == 1
+}
+
+func (ac *AccessControl) Allowed(r *http.Request) bool {
+	if len(ac.nets) == 0 && ac.token == "" {
+		return true
+	}
+
+	return ac.allowedAddr(r.RemoteAddr) || ac.allowedToken(r.Header.Get("Authorization"))
+}
+
+func (ac *AccessControl) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ac.Allowed(r) {
+			if ac.token != "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package httpx
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessControl_Allowed(t *testing.T) {
+	ac, err := NewAccessControl([]string{"127.0.0.0/8", "::1", "10.1.2.3"}, "secret")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r := httptest.NewRequest("GET", "/fpm/www/status", nil)
+
+	r.RemoteAddr = "127.0.0.1:53422"
+	assert.True(t, ac.Allowed(r))
+
+	r.RemoteAddr = "[::1]:53422"
+	assert.True(t, ac.Allowed(r))
+
+	r.RemoteAddr = "10.1.2.3:53422"
+	assert.True(t, ac.Allowed(r))
+
+	r.RemoteAddr = "10.1.2.4:53422"
+	assert.False(t, ac.Allowed(r))
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	assert.False(t, ac.Allowed(r))
+
+	r.Header.Set("Authorization", "Bearer secret")
+	assert.True(t, ac.Allowed(r))
+}
+
+func TestAccessControl_Open(t *testing.T) {
+	ac, err := NewAccessControl(nil, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r := httptest.NewRequest("GET", "/fpm/www/status", nil)
+	r.RemoteAddr = "10.1.2.4:53422"
+	assert.True(t, ac.Allowed(r))
+
+	_, err = NewAccessControl([]string{"not a network"}, "")
+	assert.Error(t, err)
+}
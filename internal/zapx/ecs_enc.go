@@ -0,0 +1,26 @@
+package zapx
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+const ecsVersion = "1.6.0"
+
+// NewECSEncoder creates json encoder with Elastic Common Schema field names.
+// Keys and time format of cfg are replaced by the schema ones, other encoders are kept.
+func NewECSEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	cfg.TimeKey = "@timestamp"
+	cfg.LevelKey = "log.level"
+	cfg.NameKey = "log.logger"
+	cfg.CallerKey = "log.origin.file.name"
+	cfg.FunctionKey = zapcore.OmitKey
+	cfg.MessageKey = "message"
+	cfg.StacktraceKey = "error.stack_trace"
+	cfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	cfg.EncodeLevel = zapcore.LowercaseLevelEncoder
+
+	enc := zapcore.NewJSONEncoder(cfg)
+	enc.AddString("ecs.version", ecsVersion)
+
+	return enc
+}
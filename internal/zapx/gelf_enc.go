@@ -0,0 +1,194 @@
+package zapx
+
+import (
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const gelfVersion = "1.1"
+
+// gelfEncoder writes GELF 1.1 json payload, top level fields are additional fields prefixed with underscore
+type gelfEncoder struct {
+	zapcore.Encoder
+	// nested is set when a namespace is opened, keys inside of it aren't prefixed
+	nested bool
+}
+
+// NewGELFEncoder creates GELF json encoder, host is the required source host field.
+// Keys and time format of cfg are replaced by the GELF ones, other encoders are kept.
+func NewGELFEncoder(cfg zapcore.EncoderConfig, host string) zapcore.Encoder {
+	cfg.TimeKey = "timestamp"
+	cfg.LevelKey = "level"
+	cfg.NameKey = "_channel"
+	cfg.CallerKey = "_caller"
+	cfg.FunctionKey = zapcore.OmitKey
+	cfg.MessageKey = "short_message"
+	cfg.StacktraceKey = "full_message"
+	cfg.EncodeTime = zapcore.EpochTimeEncoder
+	cfg.EncodeLevel = gelfLevelEncoder
+
+	enc := zapcore.NewJSONEncoder(cfg)
+	enc.AddString("version", gelfVersion)
+	enc.AddString("host", host)
+
+	return &gelfEncoder{Encoder: enc}
+}
+
+// gelfLevelEncoder encodes level as syslog severity
+func gelfLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case zapcore.DebugLevel:
+		enc.AppendInt(7)
+	case zapcore.InfoLevel:
+		enc.AppendInt(6)
+	case zapcore.WarnLevel:
+		enc.AppendInt(4)
+	case zapcore.ErrorLevel:
+		enc.AppendInt(3)
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		enc.AppendInt(2)
+	default:
+		enc.AppendInt(1)
+	}
+}
+
+// gelfFieldKey returns the additional field key, "_id" is reserved by GELF and dropped by Graylog
+func gelfFieldKey(key string) string {
+	if key == "id" {
+		return "_id_"
+	}
+
+	return "_" + key
+}
+
+func (e *gelfEncoder) key(key string) string {
+	if e.nested {
+		return key
+	}
+
+	return gelfFieldKey(key)
+}
+
+func (e *gelfEncoder) Clone() zapcore.Encoder {
+	return &gelfEncoder{Encoder: e.Encoder.Clone(), nested: e.nested}
+}
+
+func (e *gelfEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if !e.nested && len(fields) > 0 {
+		prefixed := make([]zapcore.Field, len(fields))
+		nested := false
+		for i := range fields {
+			prefixed[i] = fields[i]
+			if !nested {
+				prefixed[i].Key = gelfFieldKey(fields[i].Key)
+				nested = fields[i].Type == zapcore.NamespaceType
+			}
+		}
+		fields = prefixed
+	}
+
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+func (e *gelfEncoder) OpenNamespace(key string) {
+	e.Encoder.OpenNamespace(e.key(key))
+	e.nested = true
+}
+
+func (e *gelfEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	return e.Encoder.AddArray(e.key(key), marshaler)
+}
+
+func (e *gelfEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	return e.Encoder.AddObject(e.key(key), marshaler)
+}
+
+func (e *gelfEncoder) AddReflected(key string, value interface{}) error {
+	return e.Encoder.AddReflected(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddBinary(key string, value []byte) {
+	e.Encoder.AddBinary(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddByteString(key string, value []byte) {
+	e.Encoder.AddByteString(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddBool(key string, value bool) {
+	e.Encoder.AddBool(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddComplex128(key string, value complex128) {
+	e.Encoder.AddComplex128(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddComplex64(key string, value complex64) {
+	e.Encoder.AddComplex64(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddDuration(key string, value time.Duration) {
+	e.Encoder.AddDuration(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddFloat64(key string, value float64) {
+	e.Encoder.AddFloat64(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddFloat32(key string, value float32) {
+	e.Encoder.AddFloat32(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddInt(key string, value int) {
+	e.Encoder.AddInt(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddInt64(key string, value int64) {
+	e.Encoder.AddInt64(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddInt32(key string, value int32) {
+	e.Encoder.AddInt32(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddInt16(key string, value int16) {
+	e.Encoder.AddInt16(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddInt8(key string, value int8) {
+	e.Encoder.AddInt8(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddString(key, value string) {
+	e.Encoder.AddString(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddTime(key string, value time.Time) {
+	e.Encoder.AddTime(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddUint(key string, value uint) {
+	e.Encoder.AddUint(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddUint64(key string, value uint64) {
+	e.Encoder.AddUint64(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddUint32(key string, value uint32) {
+	e.Encoder.AddUint32(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddUint16(key string, value uint16) {
+	e.Encoder.AddUint16(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddUint8(key string, value uint8) {
+	e.Encoder.AddUint8(e.key(key), value)
+}
+
+func (e *gelfEncoder) AddUintptr(key string, value uintptr) {
+	e.Encoder.AddUintptr(e.key(key), value)
+}
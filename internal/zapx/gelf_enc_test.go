@@ -0,0 +1,59 @@
+package zapx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestGELFEncoder(t *testing.T) {
+	enc := NewGELFEncoder(testEncoderConfig(), "app-1")
+	enc.AddString("pod", "app-1")
+
+	ent := testEntry()
+	ent.Stack = "main.main()"
+
+	buf, err := enc.EncodeEntry(ent, []zapcore.Field{
+		zap.Int("pid", 42),
+		zap.String("id", "req-1"),
+		zap.Namespace("request"),
+		zap.String("id", "nested"),
+	})
+	require.NoError(t, err)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+
+	assert.Equal(t, map[string]interface{}{
+		"version":       "1.1",
+		"host":          "app-1",
+		"timestamp":     1736510400.0,
+		"level":         4.0,
+		"_channel":      "php-fpm",
+		"short_message": "child exited",
+		"full_message":  "main.main()",
+		"_pod":          "app-1",
+		"_id_":          "req-1",
+		"_pid":          42.0,
+		"_request":      map[string]interface{}{"id": "nested"},
+	}, record)
+}
+
+func TestECSEncoder(t *testing.T) {
+	buf, err := NewECSEncoder(testEncoderConfig()).EncodeEntry(testEntry(), []zapcore.Field{zap.Int("pid", 42)})
+	require.NoError(t, err)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+
+	assert.Equal(t, "1.6.0", record["ecs.version"])
+	assert.Equal(t, "warn", record["log.level"])
+	assert.Equal(t, "php-fpm", record["log.logger"])
+	assert.Equal(t, "child exited", record["message"])
+	assert.Equal(t, 42.0, record["pid"])
+	assert.Contains(t, record["@timestamp"], "2025-01-10T12:00:00")
+}
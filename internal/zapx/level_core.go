@@ -0,0 +1,36 @@
+package zapx
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelCore filters entries of the wrapped core by its own level, the wrapped core must enable all levels
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.level.Enabled(l)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+
+	return c.Core.Check(ent, ce)
+}
+
+// WithLevel returns logger which writes entries enabled by level, unlike zap.IncreaseLevel
+// the level may be lower than the level of log, so log must be created with all levels enabled.
+func WithLevel(log *zap.Logger, level zapcore.LevelEnabler) *zap.Logger {
+	return log.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: level}
+	}))
+}
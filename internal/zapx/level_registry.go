@@ -0,0 +1,116 @@
+package zapx
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelRegistry keeps levels of log channels, so they can be changed at runtime
+type LevelRegistry struct {
+	mu     sync.Mutex
+	levels map[string]zap.AtomicLevel
+	// saved levels are restored when debug is toggled off
+	saved map[string]zapcore.Level
+}
+
+func NewLevelRegistry() *LevelRegistry {
+	return &LevelRegistry{levels: make(map[string]zap.AtomicLevel)}
+}
+
+// Register creates level of the channel, the existing level is returned for known channel
+func (r *LevelRegistry) Register(channel string, level zapcore.Level) zap.AtomicLevel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if atomicLevel, ok := r.levels[channel]; ok {
+		return atomicLevel
+	}
+
+	atomicLevel := zap.NewAtomicLevelAt(level)
+	r.levels[channel] = atomicLevel
+
+	return atomicLevel
+}
+
+func (r *LevelRegistry) Get(channel string) (zap.AtomicLevel, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	atomicLevel, ok := r.levels[channel]
+
+	return atomicLevel, ok
+}
+
+// ToggleDebug switches all channels to debug, the next call restores previous levels.
+// It returns true when debug was turned on.
+func (r *LevelRegistry) ToggleDebug() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.saved != nil {
+		for channel, level := range r.saved {
+			r.levels[channel].SetLevel(level)
+		}
+		r.saved = nil
+
+		return false
+	}
+
+	r.saved = make(map[string]zapcore.Level, len(r.levels))
+	for channel, atomicLevel := range r.levels {
+		r.saved[channel] = atomicLevel.Level()
+		atomicLevel.SetLevel(zapcore.DebugLevel)
+	}
+
+	return true
+}
+
+func (r *LevelRegistry) serveAll(w http.ResponseWriter) {
+	r.mu.Lock()
+	result := make(map[string]string, len(r.levels))
+	channels := make([]string, 0, len(r.levels))
+	for channel, atomicLevel := range r.levels {
+		result[channel] = atomicLevel.Level().String()
+		channels = append(channels, channel)
+	}
+	r.mu.Unlock()
+
+	sort.Strings(channels)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Channels []string          `json:"channels"`
+		Levels   map[string]string `json:"levels"`
+	}{Channels: channels, Levels: result})
+}
+
+// Handler serves levels under prefix: GET prefix lists all channels,
+// GET and PUT prefix/{channel} are served by zap level handler, e.g. PUT {"level":"debug"}.
+func (r *LevelRegistry) Handler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		channel := strings.Trim(strings.TrimPrefix(req.URL.Path, prefix), "/")
+		if channel == "" {
+			if req.Method != http.MethodGet {
+				http.Error(w, "use "+prefix+"/{channel} to change level", http.StatusMethodNotAllowed)
+				return
+			}
+
+			r.serveAll(w)
+			return
+		}
+
+		atomicLevel, ok := r.Get(channel)
+		if !ok {
+			http.Error(w, "unknown channel: "+channel, http.StatusNotFound)
+			return
+		}
+
+		atomicLevel.ServeHTTP(w, req)
+	})
+}
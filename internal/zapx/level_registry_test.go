@@ -0,0 +1,57 @@
+package zapx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithLevel(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	registry := NewLevelRegistry()
+
+	log := WithLevel(zap.New(core), registry.Register("app", zapcore.InfoLevel)).With(zap.String("pod", "app-1"))
+	log.Debug("hidden")
+	log.Info("shown")
+
+	assert.True(t, registry.ToggleDebug())
+	log.Debug("debug on")
+
+	assert.False(t, registry.ToggleDebug())
+	log.Debug("hidden again")
+
+	messages := make([]string, 0, logs.Len())
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+		assert.Equal(t, "app-1", entry.ContextMap()["pod"])
+	}
+
+	assert.Equal(t, []string{"shown", "debug on"}, messages)
+}
+
+func TestLevelRegistry_Handler(t *testing.T) {
+	registry := NewLevelRegistry()
+	level := registry.Register("errlog", zapcore.WarnLevel)
+	registry.Register("app", zapcore.InfoLevel)
+
+	h := registry.Handler("/loglevel")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loglevel", nil))
+	assert.JSONEq(t, `{"channels":["app","errlog"],"levels":{"app":"info","errlog":"warn"}}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/loglevel/errlog", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loglevel/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,372 @@
+package zapx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder writes entries as key=value pairs. Arrays, objects and reflected values are written as quoted json,
+// keys of opened namespaces are joined with dot.
+type logfmtEncoder struct {
+	cfg        *zapcore.EncoderConfig
+	buf        *buffer.Buffer
+	namespaces []string
+}
+
+// NewLogfmtEncoder creates logfmt encoder, keys and value encoders are taken from cfg
+func NewLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: &cfg, buf: logfmtPool.Get()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := e.clone()
+	_, _ = clone.buf.Write(e.buf.Bytes())
+
+	return clone
+}
+
+func (e *logfmtEncoder) clone() *logfmtEncoder {
+	return &logfmtEncoder{
+		cfg:        e.cfg,
+		buf:        logfmtPool.Get(),
+		namespaces: append([]string(nil), e.namespaces...),
+	}
+}
+
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get()}
+
+	if final.cfg.TimeKey != "" {
+		final.AddTime(final.cfg.TimeKey, ent.Time)
+	}
+
+	if final.cfg.LevelKey != "" && final.cfg.EncodeLevel != nil {
+		final.addKey(final.cfg.LevelKey)
+		final.cfg.EncodeLevel(ent.Level, final)
+	}
+
+	if final.cfg.NameKey != "" && ent.LoggerName != "" {
+		final.addKey(final.cfg.NameKey)
+		if final.cfg.EncodeName != nil {
+			final.cfg.EncodeName(ent.LoggerName, final)
+		} else {
+			final.AppendString(ent.LoggerName)
+		}
+	}
+
+	if final.cfg.CallerKey != "" && ent.Caller.Defined && final.cfg.EncodeCaller != nil {
+		final.addKey(final.cfg.CallerKey)
+		final.cfg.EncodeCaller(ent.Caller, final)
+	}
+
+	if final.cfg.MessageKey != "" {
+		final.AddString(final.cfg.MessageKey, ent.Message)
+	}
+
+	if e.buf.Len() > 0 {
+		final.separate()
+		_, _ = final.buf.Write(e.buf.Bytes())
+	}
+
+	final.namespaces = e.namespaces
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.namespaces = nil
+
+	if final.cfg.StacktraceKey != "" && ent.Stack != "" {
+		final.AddString(final.cfg.StacktraceKey, ent.Stack)
+	}
+
+	if final.cfg.LineEnding != "" {
+		final.buf.AppendString(final.cfg.LineEnding)
+	} else {
+		final.buf.AppendString(zapcore.DefaultLineEnding)
+	}
+
+	return final.buf, nil
+}
+
+func (e *logfmtEncoder) separate() {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+}
+
+func (e *logfmtEncoder) addKey(key string) {
+	e.separate()
+
+	for _, ns := range e.namespaces {
+		e.appendKeyPart(ns)
+		e.buf.AppendByte('.')
+	}
+
+	e.appendKeyPart(key)
+	e.buf.AppendByte('=')
+}
+
+func (e *logfmtEncoder) appendKeyPart(key string) {
+	e.buf.AppendString(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+
+		return r
+	}, key))
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *logfmtEncoder) addJSON(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	e.AddByteString(key, data)
+
+	return nil
+}
+
+func (e *logfmtEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, marshaler); err != nil {
+		return err
+	}
+
+	return e.addJSON(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddObject(key, marshaler); err != nil {
+		return err
+	}
+
+	return e.addJSON(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddReflected(key string, value interface{}) error {
+	return e.addJSON(key, value)
+}
+
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.namespaces = append(e.namespaces, key)
+}
+
+func (e *logfmtEncoder) AddBinary(key string, value []byte) {
+	e.AddString(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, value []byte) {
+	e.addKey(key)
+	e.AppendByteString(value)
+}
+
+func (e *logfmtEncoder) AddBool(key string, value bool) {
+	e.addKey(key)
+	e.AppendBool(value)
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, value complex128) {
+	e.addKey(key)
+	e.AppendComplex128(value)
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, value complex64) {
+	e.addKey(key)
+	e.AppendComplex64(value)
+}
+
+func (e *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	e.addKey(key)
+	if e.cfg.EncodeDuration != nil {
+		e.cfg.EncodeDuration(value, e)
+	} else {
+		e.AppendInt64(int64(value))
+	}
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, value float64) {
+	e.addKey(key)
+	e.AppendFloat64(value)
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, value float32) {
+	e.addKey(key)
+	e.AppendFloat32(value)
+}
+
+func (e *logfmtEncoder) AddInt(key string, value int) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddInt64(key string, value int64) {
+	e.addKey(key)
+	e.AppendInt64(value)
+}
+
+func (e *logfmtEncoder) AddInt32(key string, value int32) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddInt16(key string, value int16) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddInt8(key string, value int8) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddString(key, value string) {
+	e.addKey(key)
+	e.AppendString(value)
+}
+
+func (e *logfmtEncoder) AddTime(key string, value time.Time) {
+	e.addKey(key)
+	if e.cfg.EncodeTime != nil {
+		e.cfg.EncodeTime(value, e)
+	} else {
+		e.AppendInt64(value.UnixNano())
+	}
+}
+
+func (e *logfmtEncoder) AddUint(key string, value uint) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUint64(key string, value uint64) {
+	e.addKey(key)
+	e.AppendUint64(value)
+}
+
+func (e *logfmtEncoder) AddUint32(key string, value uint32) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUint16(key string, value uint16) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUint8(key string, value uint8) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUintptr(key string, value uintptr) {
+	e.AddUint64(key, uint64(value))
+}
+
+// Append* methods write a value after the key, they are used by level, time, duration and caller encoders
+
+func (e *logfmtEncoder) AppendBool(value bool) {
+	e.buf.AppendBool(value)
+}
+
+func (e *logfmtEncoder) AppendByteString(value []byte) {
+	e.AppendString(string(value))
+}
+
+func (e *logfmtEncoder) AppendComplex128(value complex128) {
+	e.buf.AppendString(strconv.FormatComplex(value, 'f', -1, 128))
+}
+
+func (e *logfmtEncoder) AppendComplex64(value complex64) {
+	e.buf.AppendString(strconv.FormatComplex(complex128(value), 'f', -1, 64))
+}
+
+func (e *logfmtEncoder) appendFloat(value float64, bitSize int) {
+	switch {
+	case math.IsNaN(value):
+		e.buf.AppendString("NaN")
+	case math.IsInf(value, 1):
+		e.buf.AppendString("+Inf")
+	case math.IsInf(value, -1):
+		e.buf.AppendString("-Inf")
+	default:
+		e.buf.AppendFloat(value, bitSize)
+	}
+}
+
+func (e *logfmtEncoder) AppendFloat64(value float64) {
+	e.appendFloat(value, 64)
+}
+
+func (e *logfmtEncoder) AppendFloat32(value float32) {
+	e.appendFloat(float64(value), 32)
+}
+
+func (e *logfmtEncoder) AppendInt(value int) {
+	e.buf.AppendInt(int64(value))
+}
+
+func (e *logfmtEncoder) AppendInt64(value int64) {
+	e.buf.AppendInt(value)
+}
+
+func (e *logfmtEncoder) AppendInt32(value int32) {
+	e.buf.AppendInt(int64(value))
+}
+
+func (e *logfmtEncoder) AppendInt16(value int16) {
+	e.buf.AppendInt(int64(value))
+}
+
+func (e *logfmtEncoder) AppendInt8(value int8) {
+	e.buf.AppendInt(int64(value))
+}
+
+func (e *logfmtEncoder) AppendString(value string) {
+	if !needsQuote(value) {
+		e.buf.AppendString(value)
+		return
+	}
+
+	e.buf.AppendString(strconv.Quote(strings.ToValidUTF8(value, string(utf8.RuneError))))
+}
+
+func (e *logfmtEncoder) AppendUint(value uint) {
+	e.buf.AppendUint(uint64(value))
+}
+
+func (e *logfmtEncoder) AppendUint64(value uint64) {
+	e.buf.AppendUint(value)
+}
+
+func (e *logfmtEncoder) AppendUint32(value uint32) {
+	e.buf.AppendUint(uint64(value))
+}
+
+func (e *logfmtEncoder) AppendUint16(value uint16) {
+	e.buf.AppendUint(uint64(value))
+}
+
+func (e *logfmtEncoder) AppendUint8(value uint8) {
+	e.buf.AppendUint(uint64(value))
+}
+
+func (e *logfmtEncoder) AppendUintptr(value uintptr) {
+	e.buf.AppendUint(uint64(value))
+}
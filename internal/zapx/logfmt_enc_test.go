@@ -0,0 +1,94 @@
+package zapx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func testEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "ts",
+		LevelKey:       "level",
+		NameKey:        "channel",
+		MessageKey:     "message",
+		StacktraceKey:  "stacktrace",
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.EpochMillisTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+}
+
+func testEntry() zapcore.Entry {
+	return zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.UnixMilli(1736510400000),
+		LoggerName: "php-fpm",
+		Message:    "child exited",
+	}
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	enc := NewLogfmtEncoder(testEncoderConfig())
+	enc.AddString("pod", "app-1")
+
+	buf, err := enc.EncodeEntry(testEntry(), []zapcore.Field{
+		zap.Int("pid", 42),
+		zap.String("uri", "/index.php?a=b"),
+		zap.Duration("duration", 1500*time.Millisecond),
+		zap.Bool("ok", false),
+		zap.Error(errors.New("exit code 1")),
+		zap.Strings("tags", []string{"a", "b"}),
+		zap.Namespace("request"),
+		zap.String("method", "GET"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		`ts=1736510400000 level=warn channel=php-fpm message="child exited" pod=app-1 pid=42 `+
+			`uri="/index.php?a=b" duration=1.5s ok=false error="exit code 1" tags="[\"a\",\"b\"]" request.method=GET`+"\n",
+		buf.String(),
+	)
+}
+
+func TestLogfmtEncoder_Escaping(t *testing.T) {
+	cfg := testEncoderConfig()
+	cfg.TimeKey = ""
+
+	ent := testEntry()
+	ent.Message = "multi\nline"
+	ent.Stack = "main.main()"
+
+	buf, err := NewLogfmtEncoder(cfg).EncodeEntry(ent, []zapcore.Field{
+		zap.String("bad key", ""),
+		zap.String("quote", `say "hi"`),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		`level=warn channel=php-fpm message="multi\nline" bad_key="" quote="say \"hi\"" stacktrace=main.main()`+"\n",
+		buf.String(),
+	)
+}
+
+func TestLogfmtEncoder_With(t *testing.T) {
+	enc := NewLogfmtEncoder(testEncoderConfig())
+	enc.OpenNamespace("ctx")
+	enc.AddInt("a", 1)
+
+	clone := enc.Clone()
+	clone.AddInt("b", 2)
+
+	buf, err := clone.EncodeEntry(zapcore.Entry{Message: "m", Time: time.UnixMilli(1)}, []zapcore.Field{zap.Int("c", 3)})
+	require.NoError(t, err)
+	assert.Equal(t, "ts=1 level=info message=m ctx.a=1 ctx.b=2 ctx.c=3\n", buf.String())
+
+	buf, err = enc.EncodeEntry(zapcore.Entry{Message: "m", Time: time.UnixMilli(1)}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ts=1 level=info message=m ctx.a=1\n", buf.String())
+}
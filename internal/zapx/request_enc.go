@@ -0,0 +1,20 @@
+package zapx
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+// EncodeRequest returns fields describing the request the worker is serving
+func EncodeRequest(proc phpfpm.ProcessStatus) []zap.Field {
+	return []zap.Field{
+		zap.String("request_method", proc.RequestMethod),
+		zap.String("request_uri", proc.RequestPath()),
+		zap.String("query_string", proc.QueryString()),
+		zap.Duration("request_duration", time.Duration(proc.RequestDuration)*time.Microsecond),
+		zap.Int("content_length", proc.ContentLength),
+	}
+}
@@ -2,6 +2,8 @@ package zapx
 
 import (
 	"path"
+	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -9,12 +11,48 @@ import (
 	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
 )
 
+const (
+	// SlowlogStripOff keeps paths as is
+	SlowlogStripOff = "off"
+	// SlowlogStripAuto removes the longest common directory of the script and trace paths
+	SlowlogStripAuto = "auto"
+)
+
+type SlowlogOptions struct {
+	// StripPrefix is SlowlogStripOff, SlowlogStripAuto or a fixed prefix removed from paths which have it
+	StripPrefix string
+	Level       zapcore.Level
+	// Message is the record message, $pool, $pid, $script and $func (the top trace function) are replaced
+	Message string
+
+	IncludePtr  bool
+	IncludePid  bool
+	IncludePool bool
+	// TraceString writes the trace as a single string with a frame per line instead of array of objects
+	TraceString bool
+}
+
+func DefaultSlowlogOptions() SlowlogOptions {
+	return SlowlogOptions{StripPrefix: SlowlogStripAuto, Level: zapcore.WarnLevel, Message: "slowlog"}
+}
+
 type SlowlogEncoder struct {
+	opts   SlowlogOptions
 	strBuf []string
+	sb     strings.Builder
 }
 
-func NewSlowlogEncoder() *SlowlogEncoder {
-	return &SlowlogEncoder{strBuf: make([]string, 0, 32)}
+func NewSlowlogEncoder(opts SlowlogOptions) *SlowlogEncoder {
+	return &SlowlogEncoder{opts: opts, strBuf: make([]string, 0, 32)}
+}
+
+func (sle *SlowlogEncoder) Level() zapcore.Level {
+	return sle.opts.Level
+}
+
+// IncludesPool reports whether Encode adds the pool field itself
+func (sle *SlowlogEncoder) IncludesPool() bool {
+	return sle.opts.IncludePool
 }
 
 func (sle *SlowlogEncoder) reset() {
@@ -32,8 +70,40 @@ func (sle *SlowlogEncoder) addDir(p string) bool {
 	return true
 }
 
+func (sle *SlowlogEncoder) autoPrefixOffset(entry phpfpm.SlowlogEntry) int {
+	sle.reset()
+
+	cutPrefix := sle.addDir(entry.ScriptFilename)
+	for i := range entry.Stacktrace {
+		if cutPrefix = cutPrefix && sle.addDir(entry.Stacktrace[i].Path); !cutPrefix {
+			break
+		}
+	}
+
+	if !cutPrefix {
+		return 0
+	}
+
+	return longestCommonPrefixOffset(sle.strBuf)
+}
+
+// trimPath returns p without the prefix, autoOffset is used in auto mode
+func (sle *SlowlogEncoder) trimPath(p string, autoOffset int) string {
+	switch sle.opts.StripPrefix {
+	case SlowlogStripAuto:
+		return p[autoOffset:]
+	case SlowlogStripOff, "":
+		return p
+	default:
+		return strings.TrimPrefix(p, sle.opts.StripPrefix)
+	}
+}
+
 func (sle *SlowlogEncoder) encodeStacktraceEntry(encoder zapcore.ObjectEncoder, entry phpfpm.SlowlogTraceEntry, pathOffset int) {
-	encoder.AddString("path", entry.Path[pathOffset:])
+	if sle.opts.IncludePtr {
+		encoder.AddString("ptr", entry.PtrHex)
+	}
+	encoder.AddString("path", sle.trimPath(entry.Path, pathOffset))
 	encoder.AddString("func", entry.FunName)
 	encoder.AddInt("line", entry.Line)
 }
@@ -58,23 +128,70 @@ func (sle *SlowlogEncoder) encodeStacktrace(stacktrace []phpfpm.SlowlogTraceEntr
 	}))
 }
 
-func (sle *SlowlogEncoder) Encode(entry phpfpm.SlowlogEntry) []zap.Field {
-	sle.reset()
+// encodeStacktraceString writes frames in php-fpm slowlog form: "[0x...] func() path:line"
+func (sle *SlowlogEncoder) encodeStacktraceString(stacktrace []phpfpm.SlowlogTraceEntry, pathOffset int) zap.Field {
+	sle.sb.Reset()
 
-	cutPrefix := sle.addDir(entry.ScriptFilename)
-	for i := range entry.Stacktrace {
-		if cutPrefix = cutPrefix && sle.addDir(entry.Stacktrace[i].Path); !cutPrefix {
-			break
+	for i := range stacktrace {
+		if i > 0 {
+			sle.sb.WriteByte('\n')
 		}
+
+		if sle.opts.IncludePtr {
+			sle.sb.WriteString("[" + stacktrace[i].PtrHex + "] ")
+		}
+		sle.sb.WriteString(stacktrace[i].FunName)
+		sle.sb.WriteByte(' ')
+		sle.sb.WriteString(sle.trimPath(stacktrace[i].Path, pathOffset))
+		sle.sb.WriteByte(':')
+		sle.sb.WriteString(strconv.Itoa(stacktrace[i].Line))
+	}
+
+	return zap.String("trace", sle.sb.String())
+}
+
+// Message returns the record message built from the message template
+func (sle *SlowlogEncoder) Message(entry phpfpm.SlowlogEntry) string {
+	if !strings.Contains(sle.opts.Message, "$") {
+		return sle.opts.Message
 	}
 
+	var funName string
+	if len(entry.Stacktrace) > 0 {
+		funName = entry.Stacktrace[0].FunName
+	}
+
+	return strings.NewReplacer(
+		"$pool", entry.PoolName,
+		"$pid", strconv.Itoa(entry.Pid),
+		"$script", sle.trimPath(entry.ScriptFilename, sle.autoPrefixOffset(entry)),
+		"$func", funName,
+	).Replace(sle.opts.Message)
+}
+
+func (sle *SlowlogEncoder) Encode(entry phpfpm.SlowlogEntry) []zap.Field {
 	pathOffset := 0
-	if cutPrefix {
-		pathOffset = longestCommonPrefixOffset(sle.strBuf)
+	if sle.opts.StripPrefix == SlowlogStripAuto {
+		pathOffset = sle.autoPrefixOffset(entry)
+	}
+
+	result := make([]zap.Field, 0, 4)
+	if sle.opts.IncludePool {
+		result = append(result, zap.String("pool", entry.PoolName))
+	}
+
+	if sle.opts.IncludePid {
+		result = append(result, zap.Int("pid", entry.Pid))
 	}
 
-	return []zap.Field{
-		zap.String("filename", entry.ScriptFilename[pathOffset:]),
-		sle.encodeStacktrace(entry.Stacktrace, pathOffset),
+	result = append(result, zap.String("filename", sle.trimPath(entry.ScriptFilename, pathOffset)))
+	if entry.Truncated {
+		result = append(result, zap.Bool("truncated", true))
 	}
+
+	if sle.opts.TraceString {
+		return append(result, sle.encodeStacktraceString(entry.Stacktrace, pathOffset))
+	}
+
+	return append(result, sle.encodeStacktrace(entry.Stacktrace, pathOffset))
 }
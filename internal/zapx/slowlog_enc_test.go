@@ -0,0 +1,83 @@
+package zapx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+func testSlowlogEntry() phpfpm.SlowlogEntry {
+	return phpfpm.SlowlogEntry{
+		PoolName:       "www",
+		Pid:            42,
+		ScriptFilename: "/var/www/app/public/index.php",
+		Stacktrace: []phpfpm.SlowlogTraceEntry{
+			{PtrHex: "0x7f01", FunName: "sleep()", Path: "/var/www/app/src/Controller.php", Line: 12},
+			{PtrHex: "0x7f02", FunName: "handle()", Path: "/var/www/app/public/index.php", Line: 3},
+		},
+	}
+}
+
+func encodeSlowlog(opts SlowlogOptions, entry phpfpm.SlowlogEntry) map[string]interface{} {
+	core, logs := observer.New(zapcore.DebugLevel)
+	zap.New(core).Info("", NewSlowlogEncoder(opts).Encode(entry)...)
+
+	return logs.All()[0].ContextMap()
+}
+
+func TestSlowlogEncoder_StripPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		filename string
+		path     string
+	}{
+		{name: "auto", prefix: SlowlogStripAuto, filename: "public/index.php", path: "src/Controller.php"},
+		{name: "off", prefix: SlowlogStripOff, filename: "/var/www/app/public/index.php", path: "/var/www/app/src/Controller.php"},
+		{name: "fixed", prefix: "/var/www/", filename: "app/public/index.php", path: "app/src/Controller.php"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultSlowlogOptions()
+			opts.StripPrefix = tt.prefix
+
+			fields := encodeSlowlog(opts, testSlowlogEntry())
+			assert.Equal(t, tt.filename, fields["filename"])
+			assert.Equal(t, tt.path, fields["trace"].([]interface{})[0].(map[string]interface{})["path"])
+		})
+	}
+}
+
+func TestSlowlogEncoder_Fields(t *testing.T) {
+	opts := DefaultSlowlogOptions()
+	opts.StripPrefix = SlowlogStripOff
+	opts.IncludePtr = true
+	opts.IncludePid = true
+	opts.IncludePool = true
+	opts.TraceString = true
+
+	assert.Equal(t, map[string]interface{}{
+		"pool":     "www",
+		"pid":      int64(42),
+		"filename": "/var/www/app/public/index.php",
+		"trace": "[0x7f01] sleep() /var/www/app/src/Controller.php:12\n" +
+			"[0x7f02] handle() /var/www/app/public/index.php:3",
+	}, encodeSlowlog(opts, testSlowlogEntry()))
+}
+
+func TestSlowlogEncoder_Message(t *testing.T) {
+	opts := DefaultSlowlogOptions()
+	assert.Equal(t, "slowlog", NewSlowlogEncoder(opts).Message(testSlowlogEntry()))
+
+	opts.Message = "slow request $script in $pool ($pid) at $func"
+	assert.Equal(t,
+		"slow request public/index.php in www (42) at sleep()",
+		NewSlowlogEncoder(opts).Message(testSlowlogEntry()),
+	)
+}
@@ -0,0 +1,121 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultMountPoint = "/sys/fs/cgroup"
+	selfCgroupPath    = "/proc/self/cgroup"
+)
+
+type Version int
+
+const (
+	V1 Version = 1
+	V2 Version = 2
+)
+
+var ErrNotFound = errors.New("cgroup not found")
+
+// Cgroup reads resource usage and limits of the cgroup the current process belongs to
+type Cgroup struct {
+	version Version
+	// v2 uses one directory for all controllers, it is stored under empty key
+	dirs map[string]string
+}
+
+// Detect finds cgroup of the current process under /sys/fs/cgroup
+func Detect() (*Cgroup, error) {
+	return Load(defaultMountPoint, selfCgroupPath)
+}
+
+func dirExists(path string) bool {
+	stat, err := os.Stat(path)
+
+	return err == nil && stat.IsDir()
+}
+
+// resolveDir returns the cgroup directory, the root of the mount is used inside cgroup namespace
+// where /proc/self/cgroup path isn't visible
+func resolveDir(mount, cgroupPath string) string {
+	if dir := filepath.Join(mount, cgroupPath); dirExists(dir) {
+		return dir
+	}
+
+	return mount
+}
+
+// Load reads cgroup membership from procCgroup file and resolves controller directories under mountPoint
+func Load(mountPoint, procCgroup string) (*Cgroup, error) {
+	f, err := os.Open(procCgroup)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	v2 := false
+	if _, err := os.Stat(filepath.Join(mountPoint, "cgroup.controllers")); err == nil {
+		v2 = true
+	}
+
+	cg := &Cgroup{version: V1, dirs: make(map[string]string)}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if v2 {
+			if parts[0] == "0" && parts[1] == "" {
+				cg.version = V2
+				cg.dirs[""] = resolveDir(mountPoint, parts[2])
+			}
+
+			continue
+		}
+
+		for _, controller := range strings.Split(parts[1], ",") {
+			switch controller {
+			case "memory", "cpu", "pids":
+				if mount := filepath.Join(mountPoint, parts[1]); dirExists(mount) {
+					cg.dirs[controller] = resolveDir(mount, parts[2])
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(cg.dirs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return cg, nil
+}
+
+func (cg *Cgroup) Version() Version {
+	return cg.version
+}
+
+func (cg *Cgroup) path(controller, file string) string {
+	if cg.version == V2 {
+		return filepath.Join(cg.dirs[""], file)
+	}
+
+	dir, ok := cg.dirs[controller]
+	if !ok {
+		return ""
+	}
+
+	return filepath.Join(dir, file)
+}
@@ -0,0 +1,52 @@
+package cgroup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCgroup_StatsV2(t *testing.T) {
+	cg, err := Load("testdata/v2", "testdata/v2.cgroup")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, V2, cg.Version())
+
+	stats, err := cg.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{
+		MemoryCurrent:       104857600,
+		MemoryMax:           268435456,
+		OOM:                 3,
+		OOMKill:             2,
+		CPUPeriods:          100,
+		CPUThrottledPeriods: 7,
+		CPUThrottled:        1500 * time.Millisecond,
+		PidsCurrent:         12,
+	}, stats)
+}
+
+func TestCgroup_StatsV1(t *testing.T) {
+	// cgroup path isn't visible in the mount, controller roots are used as inside cgroup namespace
+	cg, err := Load("testdata/v1", "testdata/v1.cgroup")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, V1, cg.Version())
+
+	stats, err := cg.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{
+		MemoryCurrent:       52428800,
+		OOMKill:             1,
+		CPUPeriods:          50,
+		CPUThrottledPeriods: 5,
+		CPUThrottled:        2 * time.Second,
+		PidsCurrent:         4,
+		PidsMax:             1024,
+	}, stats)
+}
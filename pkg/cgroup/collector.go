@@ -0,0 +1,82 @@
+package cgroup
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Collector exports cgroup usage and limits, limits are omitted when they aren't set
+type Collector struct {
+	log *zap.Logger
+	cg  *Cgroup
+
+	memoryCurrent       *prometheus.Desc
+	memoryMax           *prometheus.Desc
+	oom                 *prometheus.Desc
+	oomKill             *prometheus.Desc
+	cpuPeriods          *prometheus.Desc
+	cpuThrottledPeriods *prometheus.Desc
+	cpuThrottled        *prometheus.Desc
+	pidsCurrent         *prometheus.Desc
+	pidsMax             *prometheus.Desc
+}
+
+func NewCollector(log *zap.Logger, namespace string, cg *Cgroup) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cgroup", name), help, nil, nil)
+	}
+
+	return &Collector{
+		log: log,
+		cg:  cg,
+
+		memoryCurrent:       desc("memory_current_bytes", "Memory used by the container cgroup"),
+		memoryMax:           desc("memory_max_bytes", "Memory limit of the container cgroup"),
+		oom:                 desc("memory_oom_total", "The number of times the memory limit was reached (cgroup v2 only)"),
+		oomKill:             desc("memory_oom_kill_total", "The number of processes killed by the OOM killer"),
+		cpuPeriods:          desc("cpu_periods_total", "The number of elapsed CPU quota enforcement periods"),
+		cpuThrottledPeriods: desc("cpu_throttled_periods_total", "The number of periods the cgroup was throttled"),
+		cpuThrottled:        desc("cpu_throttled_seconds_total", "Total time the cgroup was throttled"),
+		pidsCurrent:         desc("pids_current", "The number of processes in the container cgroup"),
+		pidsMax:             desc("pids_max", "Process limit of the container cgroup"),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.memoryCurrent
+	ch <- c.memoryMax
+	ch <- c.oom
+	ch <- c.oomKill
+	ch <- c.cpuPeriods
+	ch <- c.cpuThrottledPeriods
+	ch <- c.cpuThrottled
+	ch <- c.pidsCurrent
+	ch <- c.pidsMax
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.cg.Stats()
+	if err != nil {
+		c.log.Error("can't read cgroup stats", zap.Error(err))
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.memoryCurrent, prometheus.GaugeValue, float64(stats.MemoryCurrent))
+	if stats.MemoryMax > 0 {
+		ch <- prometheus.MustNewConstMetric(c.memoryMax, prometheus.GaugeValue, float64(stats.MemoryMax))
+	}
+
+	if c.cg.Version() == V2 {
+		ch <- prometheus.MustNewConstMetric(c.oom, prometheus.CounterValue, float64(stats.OOM))
+	}
+	ch <- prometheus.MustNewConstMetric(c.oomKill, prometheus.CounterValue, float64(stats.OOMKill))
+
+	ch <- prometheus.MustNewConstMetric(c.cpuPeriods, prometheus.CounterValue, float64(stats.CPUPeriods))
+	ch <- prometheus.MustNewConstMetric(c.cpuThrottledPeriods, prometheus.CounterValue, float64(stats.CPUThrottledPeriods))
+	ch <- prometheus.MustNewConstMetric(c.cpuThrottled, prometheus.CounterValue, stats.CPUThrottled.Seconds())
+
+	ch <- prometheus.MustNewConstMetric(c.pidsCurrent, prometheus.GaugeValue, float64(stats.PidsCurrent))
+	if stats.PidsMax > 0 {
+		ch <- prometheus.MustNewConstMetric(c.pidsMax, prometheus.GaugeValue, float64(stats.PidsMax))
+	}
+}
@@ -0,0 +1,112 @@
+package cgroup
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProcessLister returns watched processes by pid with their pool name
+type ProcessLister func() map[int]string
+
+// ExitChecker tells whether the process which disappeared exited normally, e.g. a worker recycled by
+// pm.max_requests or idle timeout
+type ExitChecker func(pid int, pool string) bool
+
+// OOMWatcher logs an event when oom_kill counter of the cgroup increases.
+// The kernel doesn't tell which process was killed, so watched processes which disappeared
+// since the previous check and didn't exit normally are reported as the victims.
+type OOMWatcher struct {
+	log       *zap.Logger
+	cg        *Cgroup
+	interval  time.Duration
+	processes ProcessLister
+	exited    ExitChecker
+}
+
+// NewOOMWatcher creates watcher, exited may be nil, then all disappeared processes are reported
+func NewOOMWatcher(
+	log *zap.Logger,
+	cg *Cgroup,
+	interval time.Duration,
+	processes ProcessLister,
+	exited ExitChecker,
+) *OOMWatcher {
+	return &OOMWatcher{log: log, cg: cg, interval: interval, processes: processes, exited: exited}
+}
+
+func vanishedPids(prev, current map[int]string, exited ExitChecker) []int {
+	var result []int
+	for pid, pool := range prev {
+		if _, ok := current[pid]; ok {
+			continue
+		}
+
+		if exited != nil && exited(pid, pool) {
+			continue
+		}
+
+		result = append(result, pid)
+	}
+
+	sort.Ints(result)
+
+	return result
+}
+
+func (w *OOMWatcher) report(stats Stats, killed uint64, prev, current map[int]string) {
+	fields := []zap.Field{
+		zap.String("event", "oom_kill"),
+		zap.Uint64("killed", killed),
+		zap.Uint64("oom_kill_total", stats.OOMKill),
+		zap.Uint64("memory_current", stats.MemoryCurrent),
+		zap.Uint64("memory_max", stats.MemoryMax),
+	}
+
+	switch pids := vanishedPids(prev, current, w.exited); len(pids) {
+	case 0:
+	case 1:
+		fields = append(fields, zap.Int("pid", pids[0]), zap.String("pool", prev[pids[0]]))
+	default:
+		fields = append(fields, zap.Ints("pids", pids))
+	}
+
+	w.log.Warn("process killed by OOM killer", fields...)
+}
+
+func (w *OOMWatcher) Run(ctx context.Context) {
+	stats, err := w.cg.Stats()
+	if err != nil {
+		w.log.Error("can't read cgroup stats", zap.Error(err))
+		return
+	}
+
+	lastOOMKill := stats.OOMKill
+	processes := w.processes()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := w.cg.Stats()
+		if err != nil {
+			w.log.Error("can't read cgroup stats", zap.Error(err))
+			continue
+		}
+
+		current := w.processes()
+		if stats.OOMKill > lastOOMKill {
+			w.report(stats, stats.OOMKill-lastOOMKill, processes, current)
+		}
+
+		lastOOMKill, processes = stats.OOMKill, current
+	}
+}
@@ -0,0 +1,31 @@
+package cgroup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestOOMWatcher_report(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	// worker 11 was recycled by pm.max_requests, worker 12 was killed
+	w := NewOOMWatcher(zap.New(core), nil, 0, nil, func(pid int, pool string) bool {
+		return pid == 11 && pool == "www"
+	})
+
+	prev := map[int]string{10: "", 11: "www", 12: "api", 13: "www"}
+	current := map[int]string{10: "", 13: "www", 14: "www"}
+	w.report(Stats{OOMKill: 1}, 1, prev, current)
+
+	if assert.Equal(t, 1, logs.Len()) {
+		fields := logs.All()[0].ContextMap()
+		assert.Equal(t, int64(12), fields["pid"])
+		assert.Equal(t, "api", fields["pool"])
+		assert.NotContains(t, fields, "pids")
+	}
+
+	assert.Equal(t, []int{11, 12}, vanishedPids(prev, current, nil))
+}
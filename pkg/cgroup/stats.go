@@ -0,0 +1,180 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// v1 reports "no limit" as the biggest page aligned int64
+const v1Unlimited = 1 << 62
+
+type Stats struct {
+	MemoryCurrent uint64
+	// MemoryMax is 0 when memory isn't limited
+	MemoryMax uint64
+	// OOM is the number of times the memory limit was reached, it is not available in v1
+	OOM     uint64
+	OOMKill uint64
+
+	CPUPeriods          uint64
+	CPUThrottledPeriods uint64
+	CPUThrottled        time.Duration
+
+	PidsCurrent uint64
+	// PidsMax is 0 when the number of processes isn't limited
+	PidsMax uint64
+}
+
+// readUint reads a file with a single value, "max" and missing file are reported as 0
+func readUint(path string) (uint64, error) {
+	if path == "" {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readKeyValues reads flat keyed file like memory.events or cpu.stat, missing file is reported as empty
+func readKeyValues(path string) (map[string]uint64, error) {
+	result := make(map[string]uint64)
+	if path == "" {
+		return result, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			result[fields[0]] = value
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+func (cg *Cgroup) statsV2() (Stats, error) {
+	var (
+		s   Stats
+		err error
+	)
+
+	if s.MemoryCurrent, err = readUint(cg.path("memory", "memory.current")); err != nil {
+		return s, err
+	}
+
+	if s.MemoryMax, err = readUint(cg.path("memory", "memory.max")); err != nil {
+		return s, err
+	}
+
+	events, err := readKeyValues(cg.path("memory", "memory.events"))
+	if err != nil {
+		return s, err
+	}
+	s.OOM, s.OOMKill = events["oom"], events["oom_kill"]
+
+	cpuStat, err := readKeyValues(cg.path("cpu", "cpu.stat"))
+	if err != nil {
+		return s, err
+	}
+	s.CPUPeriods = cpuStat["nr_periods"]
+	s.CPUThrottledPeriods = cpuStat["nr_throttled"]
+	s.CPUThrottled = time.Duration(cpuStat["throttled_usec"]) * time.Microsecond
+
+	return cg.readPids(s)
+}
+
+func (cg *Cgroup) statsV1() (Stats, error) {
+	var (
+		s   Stats
+		err error
+	)
+
+	if s.MemoryCurrent, err = readUint(cg.path("memory", "memory.usage_in_bytes")); err != nil {
+		return s, err
+	}
+
+	if s.MemoryMax, err = readUint(cg.path("memory", "memory.limit_in_bytes")); err != nil {
+		return s, err
+	}
+
+	if s.MemoryMax >= v1Unlimited {
+		s.MemoryMax = 0
+	}
+
+	oomControl, err := readKeyValues(cg.path("memory", "memory.oom_control"))
+	if err != nil {
+		return s, err
+	}
+	s.OOMKill = oomControl["oom_kill"]
+
+	cpuStat, err := readKeyValues(cg.path("cpu", "cpu.stat"))
+	if err != nil {
+		return s, err
+	}
+	s.CPUPeriods = cpuStat["nr_periods"]
+	s.CPUThrottledPeriods = cpuStat["nr_throttled"]
+	s.CPUThrottled = time.Duration(cpuStat["throttled_time"])
+
+	return cg.readPids(s)
+}
+
+func (cg *Cgroup) readPids(s Stats) (Stats, error) {
+	var err error
+
+	if s.PidsCurrent, err = readUint(cg.path("pids", "pids.current")); err != nil {
+		return s, err
+	}
+
+	s.PidsMax, err = readUint(cg.path("pids", "pids.max"))
+
+	return s, err
+}
+
+// Stats reads current usage and limits, values of not enabled controllers are zero
+func (cg *Cgroup) Stats() (Stats, error) {
+	if cg.version == V2 {
+		return cg.statsV2()
+	}
+
+	return cg.statsV1()
+}
+
+// MemoryLimit returns the memory limit, 0 means no limit
+func (cg *Cgroup) MemoryLimit() (uint64, error) {
+	s, err := cg.Stats()
+
+	return s.MemoryMax, err
+}
@@ -0,0 +1,209 @@
+package fcgi
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	// DialTimeout limits connection establishment, zero means no limit
+	DialTimeout time.Duration
+	// Timeout limits the whole request including reading of response body, zero means no limit
+	Timeout time.Duration
+
+	// KeepAlive asks the server to keep connection open and reuses it for the next requests.
+	// Note: php-fpm worker serves only the kept connection until it is closed.
+	KeepAlive bool
+	// MaxIdleConns limits number of kept connections, default is 1
+	MaxIdleConns int
+	// IdleTimeout closes kept connections which were not used for the period, zero means no limit
+	IdleTimeout time.Duration
+
+	// Multiplex sends concurrent requests over one connection,
+	// use it only with servers which support FCGI_MPXS_CONNS (php-fpm doesn't)
+	Multiplex bool
+}
+
+type Request struct {
+	Params map[string]string
+	Stdin  io.Reader
+}
+
+// Client is a FastCGI client for one server address, safe for concurrent use
+type Client struct {
+	network string
+	addr    string
+	opts    Options
+
+	mu     sync.Mutex
+	idle   []*conn
+	shared *conn
+	closed bool
+}
+
+func NewClient(network, addr string, opts Options) *Client {
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 1
+	}
+
+	return &Client{network: network, addr: addr, opts: opts}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := net.Dialer{Timeout: c.opts.DialTimeout}
+	nc, err := dialer.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(nc, c.opts.KeepAlive, c.opts.Multiplex, c.putIdle), nil
+}
+
+func (c *Client) getShared(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrConnClosed
+	}
+
+	if c.shared != nil && c.shared.alive() {
+		return c.shared, nil
+	}
+
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.shared = cn
+
+	return cn, nil
+}
+
+func (c *Client) getIdle() *conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.idle) > 0 {
+		cn := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+
+		cn.mu.Lock()
+		expired := c.opts.IdleTimeout > 0 && time.Since(cn.idleSince) > c.opts.IdleTimeout
+		cn.mu.Unlock()
+
+		if expired || !cn.alive() {
+			_ = cn.Close()
+			continue
+		}
+
+		return cn
+	}
+
+	return nil
+}
+
+func (c *Client) putIdle(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= c.opts.MaxIdleConns {
+		go cn.Close()
+		return
+	}
+
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) getConn(ctx context.Context) (*conn, error) {
+	if c.opts.Multiplex {
+		return c.getShared(ctx)
+	}
+
+	if cn := c.getIdle(); cn != nil {
+		return cn, nil
+	}
+
+	return c.dial(ctx)
+}
+
+// Do sends the request and reads response headers.
+// The caller must close response body, the request stays in progress until then.
+func (c *Client) Do(ctx context.Context, r *Request) (*Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if c.opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+	}
+
+	cn, err := c.getConn(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	req, err := cn.begin()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() { cn.abort(req, ctx.Err()) })
+	fail := func(err error) (*Response, error) {
+		stop()
+		cn.abort(req, err)
+		cancel()
+
+		return nil, err
+	}
+
+	if err = cn.writeBegin(req, r.Params); err != nil {
+		return fail(err)
+	}
+
+	if r.Stdin == nil {
+		err = cn.writeStdin(req, nil)
+	} else {
+		// the server may start to respond before it reads the whole stdin
+		go func() {
+			if err := cn.writeStdin(req, r.Stdin); err != nil {
+				cn.abort(req, err)
+			}
+		}()
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	resp, err := readResponse(req, &body{cn: cn, req: req, stop: stop, cancel: cancel})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+
+		return fail(err)
+	}
+
+	return resp, nil
+}
+
+// Close closes idle connections and the shared one, requests sent over the shared connection are interrupted
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	shared := c.shared
+	c.idle, c.shared, c.closed = nil, nil, true
+	c.mu.Unlock()
+
+	for _, cn := range idle {
+		_ = cn.Close()
+	}
+
+	if shared != nil {
+		_ = shared.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package fcgi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeParams(data []byte) map[string]string {
+	readLen := func() int {
+		if data[0]&0x80 == 0 {
+			l := int(data[0])
+			data = data[1:]
+			return l
+		}
+
+		l := int(binary.BigEndian.Uint32(data) &^ (1 << 31))
+		data = data[4:]
+		return l
+	}
+
+	result := make(map[string]string)
+	for len(data) > 0 {
+		nameLen, valueLen := readLen(), readLen()
+		result[string(data[:nameLen])] = string(data[nameLen : nameLen+valueLen])
+		data = data[nameLen+valueLen:]
+	}
+
+	return result
+}
+
+type testHandler func(params map[string]string, stdin []byte) (stdout string, stderr string)
+
+type testServer struct {
+	listener net.Listener
+	handler  testHandler
+	conns    atomic.Int32
+}
+
+func newTestServer(t *testing.T, handler testHandler) *testServer {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "fcgi.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{listener: l, handler: handler}
+	go s.serve()
+	t.Cleanup(func() { _ = l.Close() })
+
+	return s
+}
+
+func (s *testServer) serve() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.conns.Add(1)
+		go s.serveConn(nc)
+	}
+}
+
+func (s *testServer) serveConn(nc net.Conn) {
+	defer func() { _ = nc.Close() }()
+
+	var (
+		buf      []byte
+		params   []byte
+		stdin    []byte
+		keepConn bool
+	)
+
+	br := bufio.NewReader(nc)
+	for {
+		header, content, err := readRecord(br, &buf)
+		if err != nil {
+			return
+		}
+
+		switch header.Type {
+		case typeBeginRequest:
+			keepConn = content[2]&flagKeepConn != 0
+			params, stdin = params[:0], stdin[:0]
+		case typeParams:
+			params = append(params, content...)
+		case typeStdin:
+			if len(content) > 0 {
+				stdin = append(stdin, content...)
+				continue
+			}
+
+			stdout, stderr := s.handler(decodeParams(params), stdin)
+			_ = writeStream(nc, typeStdout, header.RequestID, []byte(stdout))
+			_ = writeStream(nc, typeStderr, header.RequestID, []byte(stderr))
+			_ = writeRecord(nc, typeEndRequest, header.RequestID, make([]byte, 8))
+
+			if !keepConn {
+				return
+			}
+		}
+	}
+}
+
+func (s *testServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func TestClient_Do(t *testing.T) {
+	s := newTestServer(t, func(params map[string]string, stdin []byte) (string, string) {
+		if params["SCRIPT_NAME"] != "/status" {
+			return "Status: 404 Not Found\r\nContent-type: text/html\r\n\r\nFile not found.\n", "Primary script unknown"
+		}
+
+		return "Content-type: text/plain\r\n\r\n" + params["QUERY_STRING"] + ":" + string(stdin), ""
+	})
+
+	c := NewClient("unix", s.addr(), Options{DialTimeout: time.Second})
+	defer c.Close()
+
+	resp, err := c.Do(context.Background(), &Request{
+		Params: map[string]string{"SCRIPT_NAME": "/status", "QUERY_STRING": "json&full"},
+		Stdin:  strings.NewReader("body"),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "json&full:body", string(data))
+
+	resp, err = c.Do(context.Background(), &Request{Params: map[string]string{"SCRIPT_NAME": "/unknown"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err = io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "File not found.\n", string(data))
+	assert.Equal(t, "Primary script unknown", string(resp.Stderr()))
+	assert.Empty(t, resp.Header.Get("Status"))
+}
+
+func TestClient_KeepAlive(t *testing.T) {
+	s := newTestServer(t, func(params map[string]string, stdin []byte) (string, string) {
+		return "\r\npong", ""
+	})
+
+	c := NewClient("unix", s.addr(), Options{KeepAlive: true})
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.Do(context.Background(), &Request{})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "pong", string(data))
+		assert.NoError(t, resp.Body.Close())
+	}
+
+	assert.Equal(t, int32(1), s.conns.Load())
+}
+
+func TestClient_Timeout(t *testing.T) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "fcgi.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		// accept and never respond
+		nc, err := l.Accept()
+		if err == nil {
+			_, _ = io.Copy(io.Discard, nc)
+		}
+	}()
+
+	c := NewClient("unix", l.Addr().String(), Options{Timeout: 50 * time.Millisecond})
+	defer c.Close()
+
+	_, err = c.Do(context.Background(), &Request{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,322 @@
+package fcgi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	ErrConnClosed  = errors.New("fcgi: connection closed")
+	ErrOverloaded  = errors.New("fcgi: server overloaded")
+	ErrCantMpxConn = errors.New("fcgi: server can't multiplex connection")
+	ErrUnknownRole = errors.New("fcgi: unknown role")
+
+	errBodyClosed = errors.New("fcgi: response body closed")
+)
+
+type request struct {
+	id uint16
+
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+	stderr  bytes.Buffer
+
+	appStatus uint32
+	err       error
+	done      chan struct{}
+}
+
+func newRequest(id uint16) *request {
+	pr, pw := io.Pipe()
+
+	return &request{id: id, stdoutR: pr, stdoutW: pw, done: make(chan struct{})}
+}
+
+// finish must be called once, by the one who removed the request from conn
+func (r *request) finish(err error) {
+	r.err = err
+	_ = r.stdoutW.CloseWithError(err)
+	close(r.done)
+}
+
+type conn struct {
+	nc        net.Conn
+	keepConn  bool
+	multiplex bool
+	onIdle    func(*conn)
+
+	wmu sync.Mutex
+	bw  *bufio.Writer
+
+	mu        sync.Mutex
+	reqs      map[uint16]*request
+	nextID    uint16
+	err       error
+	idleSince time.Time
+}
+
+func newConn(nc net.Conn, keepConn, multiplex bool, onIdle func(*conn)) *conn {
+	c := &conn{
+		nc:        nc,
+		keepConn:  keepConn || multiplex,
+		multiplex: multiplex,
+		onIdle:    onIdle,
+		bw:        bufio.NewWriterSize(nc, 8*1024),
+		reqs:      make(map[uint16]*request),
+	}
+
+	go c.readLoop()
+
+	return c
+}
+
+func (c *conn) alive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err == nil
+}
+
+func (c *conn) begin() (*request, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	for {
+		c.nextID++
+		if c.nextID == 0 {
+			// request id 0 is reserved for management records
+			continue
+		}
+
+		if _, ok := c.reqs[c.nextID]; !ok {
+			break
+		}
+	}
+
+	req := newRequest(c.nextID)
+	c.reqs[req.id] = req
+
+	return req, nil
+}
+
+func (c *conn) remove(req *request) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reqs[req.id] != req {
+		return false
+	}
+	delete(c.reqs, req.id)
+
+	return true
+}
+
+func (c *conn) closeWithError(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+
+	c.err = err
+	reqs := c.reqs
+	c.reqs = make(map[uint16]*request)
+	c.mu.Unlock()
+
+	_ = c.nc.Close()
+
+	for _, req := range reqs {
+		req.finish(err)
+	}
+}
+
+func (c *conn) Close() error {
+	c.closeWithError(ErrConnClosed)
+
+	return nil
+}
+
+// abort cancels the request which is still in progress
+func (c *conn) abort(req *request, err error) {
+	if !c.multiplex {
+		// php-fpm doesn't support FCGI_ABORT_REQUEST, the only way to stop the request is to drop the connection
+		c.mu.Lock()
+		active := c.reqs[req.id] == req
+		c.mu.Unlock()
+
+		if active {
+			c.closeWithError(err)
+		}
+
+		return
+	}
+
+	if !c.remove(req) {
+		return
+	}
+
+	c.wmu.Lock()
+	if err := writeRecord(c.bw, typeAbortRequest, req.id, nil); err == nil {
+		_ = c.bw.Flush()
+	}
+	c.wmu.Unlock()
+
+	req.finish(err)
+}
+
+func (c *conn) writeRecords(fn func(w io.Writer) error) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if err := fn(c.bw); err != nil {
+		return err
+	}
+
+	return c.bw.Flush()
+}
+
+func (c *conn) writeBegin(req *request, params map[string]string) error {
+	return c.writeRecords(func(w io.Writer) error {
+		var body [8]byte
+		binary.BigEndian.PutUint16(body[0:], roleResponder)
+		if c.keepConn {
+			body[2] = flagKeepConn
+		}
+
+		if err := writeRecord(w, typeBeginRequest, req.id, body[:]); err != nil {
+			return err
+		}
+
+		if err := writeStream(w, typeParams, req.id, encodeParams(params)); err != nil {
+			return err
+		}
+
+		return writeRecord(w, typeParams, req.id, nil)
+	})
+}
+
+func (c *conn) writeStdin(req *request, stdin io.Reader) error {
+	if stdin != nil {
+		buf := make([]byte, maxWrite)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				werr := c.writeRecords(func(w io.Writer) error {
+					return writeRecord(w, typeStdin, req.id, buf[:n])
+				})
+				if werr != nil {
+					return werr
+				}
+			}
+
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return c.writeRecords(func(w io.Writer) error {
+		return writeRecord(w, typeStdin, req.id, nil)
+	})
+}
+
+func (c *conn) endRequest(req *request, content []byte) {
+	if !c.remove(req) {
+		return
+	}
+
+	var err error
+	if len(content) >= 5 {
+		req.appStatus = binary.BigEndian.Uint32(content)
+
+		switch content[4] {
+		case statusRequestComplete:
+		case statusCantMultiplexConn:
+			err = ErrCantMpxConn
+		case statusOverloaded:
+			err = ErrOverloaded
+		case statusUnknownRole:
+			err = ErrUnknownRole
+		default:
+			err = fmt.Errorf("fcgi: unknown protocol status %d", content[4])
+		}
+	}
+
+	req.finish(err)
+}
+
+func (c *conn) release() {
+	if !c.keepConn {
+		c.closeWithError(ErrConnClosed)
+		return
+	}
+
+	if c.multiplex || c.onIdle == nil {
+		return
+	}
+
+	c.mu.Lock()
+	idle := c.err == nil && len(c.reqs) == 0
+	if idle {
+		c.idleSince = time.Now()
+	}
+	c.mu.Unlock()
+
+	if idle {
+		c.onIdle(c)
+	}
+}
+
+func (c *conn) readLoop() {
+	br := bufio.NewReaderSize(c.nc, 16*1024)
+	var buf []byte
+
+	for {
+		header, content, err := readRecord(br, &buf)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				err = ErrConnClosed
+			}
+
+			c.closeWithError(err)
+			return
+		}
+
+		c.mu.Lock()
+		req := c.reqs[header.RequestID]
+		c.mu.Unlock()
+
+		if req == nil {
+			// management record or aborted request
+			continue
+		}
+
+		switch header.Type {
+		case typeStdout:
+			if len(content) > 0 {
+				// fails only when body was closed, the rest of output is discarded
+				_, _ = req.stdoutW.Write(content)
+			}
+		case typeStderr:
+			req.stderr.Write(content)
+		case typeEndRequest:
+			c.endRequest(req, content)
+			c.release()
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package fcgi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const defaultIndex = "index.php"
+
+// Gateway serves http requests by the FastCGI application.
+// Request path is mapped to the script in DocumentRoot, paths without php script are handled by Index front controller.
+type Gateway struct {
+	Client       *Client
+	DocumentRoot string
+	Index        string
+}
+
+func NewGateway(client *Client, documentRoot, index string) *Gateway {
+	if index == "" {
+		index = defaultIndex
+	}
+
+	return &Gateway{Client: client, DocumentRoot: strings.TrimRight(documentRoot, "/"), Index: index}
+}
+
+// splitScript splits request path into script name and path info
+func (g *Gateway) splitScript(urlPath string) (string, string) {
+	urlPath = path.Clean("/" + urlPath)
+
+	if pos := strings.Index(urlPath, ".php/"); pos != -1 {
+		return urlPath[:pos+4], urlPath[pos+4:]
+	}
+
+	if strings.HasSuffix(urlPath, ".php") {
+		return urlPath, ""
+	}
+
+	return "/" + g.Index, ""
+}
+
+func (g *Gateway) params(r *http.Request) map[string]string {
+	scriptName, pathInfo := g.splitScript(r.URL.Path)
+
+	serverName, serverPort, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		serverName, serverPort = r.Host, "80"
+	}
+
+	remoteAddr, remotePort, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteAddr = r.RemoteAddr
+	}
+
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "docker-fpm-wrapper",
+		"SERVER_PROTOCOL":   r.Proto,
+		"SERVER_NAME":       serverName,
+		"SERVER_PORT":       serverPort,
+		"REQUEST_METHOD":    r.Method,
+		"REQUEST_URI":       r.URL.RequestURI(),
+		"QUERY_STRING":      r.URL.RawQuery,
+		"DOCUMENT_ROOT":     g.DocumentRoot,
+		"DOCUMENT_URI":      scriptName,
+		"SCRIPT_NAME":       scriptName,
+		"SCRIPT_FILENAME":   g.DocumentRoot + scriptName,
+		"PATH_INFO":         pathInfo,
+		"REMOTE_ADDR":       remoteAddr,
+		"REMOTE_PORT":       remotePort,
+		"CONTENT_TYPE":      r.Header.Get("Content-Type"),
+		// the server moves Host header into r.Host
+		"HTTP_HOST": r.Host,
+	}
+
+	params["CONTENT_LENGTH"] = strconv.FormatInt(r.ContentLength, 10)
+
+	if r.TLS != nil {
+		params["HTTPS"] = "on"
+	}
+
+	for name, values := range r.Header {
+		// https://httpoxy.org
+		if name == "Proxy" {
+			continue
+		}
+
+		key := "HTTP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		params[key] = strings.Join(values, ", ")
+	}
+
+	return params
+}
+
+func (g *Gateway) copyBody(w http.ResponseWriter, body io.Reader) error {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// php-fpm reads the body by CONTENT_LENGTH, so chunked bodies of unknown length can't be passed
+	if r.ContentLength < 0 {
+		http.Error(w, http.StatusText(http.StatusLengthRequired), http.StatusLengthRequired)
+		return
+	}
+
+	req := &Request{Params: g.params(r)}
+	if r.ContentLength != 0 {
+		req.Stdin = r.Body
+	}
+
+	resp, err := g.Client.Do(r.Context(), req)
+	if err != nil {
+		code := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+		}
+
+		http.Error(w, err.Error(), code)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	// the response is already started, there is no way to report the error
+	_ = g.copyBody(w, resp.Body)
+}
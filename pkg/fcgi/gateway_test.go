@@ -0,0 +1,60 @@
+package fcgi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGateway_splitScript(t *testing.T) {
+	g := NewGateway(nil, "/var/www/html/", "")
+
+	tests := []struct {
+		path       string
+		scriptName string
+		pathInfo   string
+	}{
+		{path: "/", scriptName: "/index.php"},
+		{path: "/health", scriptName: "/index.php"},
+		{path: "/app.php", scriptName: "/app.php"},
+		{path: "/app.php/users/1", scriptName: "/app.php", pathInfo: "/users/1"},
+		{path: "/../../etc/passwd.php", scriptName: "/etc/passwd.php"},
+	}
+
+	for _, tt := range tests {
+		scriptName, pathInfo := g.splitScript(tt.path)
+		assert.Equal(t, tt.scriptName, scriptName, tt.path)
+		assert.Equal(t, tt.pathInfo, pathInfo, tt.path)
+	}
+}
+
+func TestGateway_ServeHTTP(t *testing.T) {
+	s := newTestServer(t, func(params map[string]string, stdin []byte) (string, string) {
+		return "Status: 201 Created\r\nX-Script: " + params["SCRIPT_FILENAME"] + "\r\n\r\n" +
+			params["REQUEST_METHOD"] + " " + params["REQUEST_URI"] + " " + params["HTTP_HOST"] + " " +
+			params["HTTP_X_REQUEST_ID"] + " " + params["CONTENT_LENGTH"] + " " + string(stdin), ""
+	})
+
+	g := NewGateway(NewClient("unix", s.addr(), Options{}), "/var/www/html", "index.php")
+
+	r := httptest.NewRequest("POST", "/api/users?debug=1", strings.NewReader("name=test"))
+	r.Header.Set("X-Request-Id", "42")
+	w := httptest.NewRecorder()
+
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "/var/www/html/index.php", w.Header().Get("X-Script"))
+	assert.Equal(t, "POST /api/users?debug=1 example.com 42 9 name=test", w.Body.String())
+
+	r = httptest.NewRequest("POST", "/api/users", strings.NewReader("name=test"))
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusLengthRequired, w.Code)
+}
@@ -0,0 +1,136 @@
+package fcgi
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const version1 = 1
+
+const (
+	typeBeginRequest = 1
+	typeAbortRequest = 2
+	typeEndRequest   = 3
+	typeParams       = 4
+	typeStdin        = 5
+	typeStdout       = 6
+	typeStderr       = 7
+)
+
+const (
+	roleResponder = 1
+	flagKeepConn  = 1
+)
+
+const (
+	headerLen = 8
+	// maxWrite is the biggest record content length aligned to 8 bytes
+	maxWrite = 65528
+)
+
+const (
+	statusRequestComplete = iota
+	statusCantMultiplexConn
+	statusOverloaded
+	statusUnknownRole
+)
+
+type recordHeader struct {
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+}
+
+var padding [headerLen]byte
+
+func writeRecord(w io.Writer, recType uint8, reqID uint16, content []byte) error {
+	var header [headerLen]byte
+
+	paddingLen := -len(content) & (headerLen - 1)
+	header[0] = version1
+	header[1] = recType
+	binary.BigEndian.PutUint16(header[2:], reqID)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(content)))
+	header[6] = uint8(paddingLen)
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+
+	_, err := w.Write(padding[:paddingLen])
+
+	return err
+}
+
+// writeStream splits data into records, empty stream terminator is not written
+func writeStream(w io.Writer, recType uint8, reqID uint16, data []byte) error {
+	for len(data) > 0 {
+		n := min(len(data), maxWrite)
+		if err := writeRecord(w, recType, reqID, data[:n]); err != nil {
+			return err
+		}
+
+		data = data[n:]
+	}
+
+	return nil
+}
+
+// readRecord reads next record, returned content is valid until the next call with the same buf
+func readRecord(r io.Reader, buf *[]byte) (recordHeader, []byte, error) {
+	var (
+		header    [headerLen]byte
+		recHeader recordHeader
+	)
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return recHeader, nil, err
+	}
+
+	recHeader.Type = header[1]
+	recHeader.RequestID = binary.BigEndian.Uint16(header[2:])
+	recHeader.ContentLength = binary.BigEndian.Uint16(header[4:])
+	recHeader.PaddingLength = header[6]
+
+	n := int(recHeader.ContentLength) + int(recHeader.PaddingLength)
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
+	}
+
+	content := (*buf)[:n]
+	if _, err := io.ReadFull(r, content); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return recHeader, nil, err
+	}
+
+	return recHeader, content[:recHeader.ContentLength], nil
+}
+
+func appendParamLen(dst []byte, l int) []byte {
+	if l < 128 {
+		return append(dst, byte(l))
+	}
+
+	return binary.BigEndian.AppendUint32(dst, uint32(l)|1<<31)
+}
+
+func encodeParams(params map[string]string) []byte {
+	var result []byte
+
+	for name, value := range params {
+		result = appendParamLen(result, len(name))
+		result = appendParamLen(result, len(value))
+		result = append(result, name...)
+		result = append(result, value...)
+	}
+
+	return result
+}
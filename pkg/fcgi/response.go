@@ -0,0 +1,105 @@
+package fcgi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+
+	req *request
+}
+
+// Stderr returns data the application wrote to stderr, it is complete only after Body was read till EOF
+func (r *Response) Stderr() []byte {
+	select {
+	case <-r.req.done:
+		return r.req.stderr.Bytes()
+	default:
+		return nil
+	}
+}
+
+// AppStatus returns application exit status, it is set only after Body was read till EOF
+func (r *Response) AppStatus() int {
+	select {
+	case <-r.req.done:
+		return int(r.req.appStatus)
+	default:
+		return 0
+	}
+}
+
+type body struct {
+	r      *bufio.Reader
+	cn     *conn
+	req    *request
+	stop   func() bool
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+func (b *body) Close() error {
+	b.once.Do(func() {
+		b.stop()
+
+		select {
+		case <-b.req.done:
+		default:
+			b.cn.abort(b.req, errBodyClosed)
+		}
+
+		_ = b.req.stdoutR.CloseWithError(errBodyClosed)
+		b.cancel()
+	})
+
+	return nil
+}
+
+func parseStatus(status string) (int, error) {
+	code, _, _ := strings.Cut(strings.TrimSpace(status), " ")
+
+	result, err := strconv.Atoi(code)
+	if err != nil || result < 100 || result > 999 {
+		return 0, fmt.Errorf("fcgi: malformed status header %q", status)
+	}
+
+	return result, nil
+}
+
+// readResponse reads cgi response headers from stdout of the request
+func readResponse(req *request, b *body) (*Response, error) {
+	b.r = bufio.NewReader(req.stdoutR)
+
+	mimeHeader, err := textproto.NewReader(b.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("fcgi: can't read response headers: %w", err)
+	}
+
+	resp := &Response{StatusCode: http.StatusOK, Header: http.Header(mimeHeader), Body: b, req: req}
+
+	if status := resp.Header.Get("Status"); status != "" {
+		if resp.StatusCode, err = parseStatus(status); err != nil {
+			return nil, err
+		}
+		resp.Header.Del("Status")
+	} else if resp.Header.Get("Location") != "" {
+		resp.StatusCode = http.StatusFound
+	}
+
+	return resp, nil
+}
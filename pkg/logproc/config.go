@@ -0,0 +1,136 @@
+package logproc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+const (
+	TypeExtract = "extract"
+	TypeDrop    = "drop"
+	TypeRename  = "rename"
+	TypeRedact  = "redact"
+	TypeSample  = "sample"
+)
+
+// Config describes one processor of the chain, fields which aren't used by Type are ignored
+type Config struct {
+	Type string `json:"type"`
+	// Channels limits the processor to records of the channels (logger names), all records when empty
+	Channels []string `json:"channels"`
+
+	// extract: the source field and the pattern with named groups
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+
+	// drop: removed fields
+	Fields []string `json:"fields"`
+
+	// rename: old path to new path
+	Rename map[string]string `json:"rename"`
+
+	// sample: keep one of every Every records with level below Below (default warn)
+	Every int    `json:"every"`
+	Below string `json:"below"`
+}
+
+// LoadConfig reads json array of processor configs, s is either the json itself or the path of json file
+func LoadConfig(s string) ([]Config, error) {
+	data := []byte(s)
+	if !strings.HasPrefix(strings.TrimSpace(s), "[") {
+		var err error
+		if data, err = os.ReadFile(s); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("can't decode log processors: %w", err)
+	}
+
+	return result, nil
+}
+
+// NewProcessor creates processor described by cfg, redact processor uses redactor
+func NewProcessor(cfg Config, redactor *redact.Redactor) (Processor, error) {
+	p, err := newProcessor(cfg, redactor)
+	if err != nil {
+		return nil, fmt.Errorf("%s processor: %w", cfg.Type, err)
+	}
+
+	if len(cfg.Channels) == 0 {
+		return p, nil
+	}
+
+	filter := &channelFilter{channels: make(map[string]bool, len(cfg.Channels)), processor: p}
+	for _, channel := range cfg.Channels {
+		filter.channels[channel] = true
+	}
+
+	return filter, nil
+}
+
+func newProcessor(cfg Config, redactor *redact.Redactor) (Processor, error) {
+	switch cfg.Type {
+	case TypeExtract:
+		if cfg.Field == "" {
+			cfg.Field = MessageField
+		}
+
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Extract{Field: cfg.Field, Pattern: re}, nil
+	case TypeDrop:
+		return &Drop{Fields: cfg.Fields}, nil
+	case TypeRename:
+		return &Rename{Fields: cfg.Rename}, nil
+	case TypeRedact:
+		if redactor == nil {
+			return nil, errors.New("redactor isn't set")
+		}
+
+		return NewRedact(redactor), nil
+	case TypeSample:
+		below := zapcore.WarnLevel
+		if cfg.Below != "" {
+			var err error
+			if below, err = zapcore.ParseLevel(cfg.Below); err != nil {
+				return nil, err
+			}
+		}
+
+		return NewSample(cfg.Every, below), nil
+	default:
+		return nil, errors.New("unknown type")
+	}
+}
+
+// NewChain creates processors of configs in order, redact processors use redactor
+func NewChain(configs []Config, redactor *redact.Redactor) (Chain, error) {
+	result := make(Chain, 0, len(configs))
+	for _, cfg := range configs {
+		p, err := NewProcessor(cfg, redactor)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, p)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package logproc
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// core passes records through the chain before the wrapped core. Fields added by With are kept here,
+// so processors see them too.
+type core struct {
+	zapcore.Core
+	chain  Chain
+	fields []zapcore.Field
+}
+
+// NewCore wraps core with the chain, records are encoded into Record fields and written back in original order,
+// fields added by processors go last
+func NewCore(c zapcore.Core, chain Chain) zapcore.Core {
+	return &core{Core: c, chain: chain}
+}
+
+// WrapCore returns zap option which wraps logger core with the chain
+func WrapCore(chain Chain) zap.Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return NewCore(c, chain)
+	})
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append(make([]zapcore.Field, 0, len(c.fields)+len(fields)), c.fields...), fields...)
+
+	return &clone
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func addFields(enc *zapcore.MapObjectEncoder, keys []string, fields []zapcore.Field) []string {
+	for i := range fields {
+		fields[i].AddTo(enc)
+		if fields[i].Key != "" {
+			keys = append(keys, fields[i].Key)
+		}
+	}
+
+	return keys
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	keys := addFields(enc, make([]string, 0, len(c.fields)+len(fields)), c.fields)
+	keys = addFields(enc, keys, fields)
+
+	rec, keep := c.chain.Process(Record{
+		Time:    ent.Time,
+		Level:   ent.Level,
+		Channel: ent.LoggerName,
+		Message: ent.Message,
+		Fields:  enc.Fields,
+	})
+	if !keep {
+		return nil
+	}
+
+	ent.Time, ent.Level, ent.LoggerName, ent.Message = rec.Time, rec.Level, rec.Channel, rec.Message
+
+	return c.Core.Write(ent, recordFields(rec.Fields, keys))
+}
+
+// recordFields returns fields in order of keys, the rest of fields are sorted
+func recordFields(values map[string]any, keys []string) []zapcore.Field {
+	result := make([]zapcore.Field, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, key := range keys {
+		if v, ok := values[key]; ok && !seen[key] {
+			seen[key] = true
+			result = append(result, zap.Any(key, v))
+		}
+	}
+
+	rest := make([]string, 0, len(values)-len(result))
+	for key := range values {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	for _, key := range rest {
+		result = append(result, zap.Any(key, values[key]))
+	}
+
+	return result
+}
@@ -0,0 +1,101 @@
+package logproc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LineWriter passes every written line through the chain before w. A json object line is processed as fields
+// with its "message" key as the message, any other line is the message itself. Every Write must be one line.
+type LineWriter struct {
+	w       io.Writer
+	chain   Chain
+	channel string
+}
+
+// NewLineWriter creates writer of raw lines, channel is the record channel of the lines
+func NewLineWriter(w io.Writer, chain Chain, channel string) *LineWriter {
+	return &LineWriter{w: w, chain: chain, channel: channel}
+}
+
+func (lw *LineWriter) Write(p []byte) (int, error) {
+	line, ending := splitLineEnding(p)
+
+	out, keep := lw.process(line)
+	if !keep {
+		return len(p), nil
+	}
+
+	if _, err := lw.w.Write(append(out, ending...)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func splitLineEnding(p []byte) ([]byte, []byte) {
+	line := bytes.TrimRight(p, "\r\n")
+
+	return line, p[len(line):]
+}
+
+func (lw *LineWriter) process(line []byte) ([]byte, bool) {
+	rec := Record{Time: time.Now(), Level: zapcore.InfoLevel, Channel: lw.channel}
+
+	fields, isJSON := decodeJSONObject(line)
+	if !isJSON {
+		rec.Message = string(line)
+
+		rec, keep := lw.chain.Process(rec)
+
+		return []byte(rec.Message), keep
+	}
+
+	if msg, ok := fields[MessageField].(string); ok {
+		rec.Message = msg
+		delete(fields, MessageField)
+	}
+	rec.Fields = fields
+
+	rec, keep := lw.chain.Process(rec)
+	if !keep {
+		return nil, false
+	}
+
+	if rec.Fields == nil {
+		rec.Fields = make(map[string]any)
+	}
+	if rec.Message != "" {
+		rec.Fields[MessageField] = rec.Message
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(rec.Fields); err != nil {
+		return line, true
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), true
+}
+
+func decodeJSONObject(line []byte) (map[string]any, bool) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) < 2 || trimmed[0] != '{' {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+
+	var result map[string]any
+	if err := dec.Decode(&result); err != nil || result == nil {
+		return nil, false
+	}
+
+	return result, true
+}
@@ -0,0 +1,123 @@
+package logproc
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// MessageField is the path of Record.Message for processors which take field paths
+const MessageField = "message"
+
+// Record is a log record passed through processors. Fields keep nested objects as map[string]any
+// and arrays as []any, nested fields are addressed by dotted paths, e.g. "context.user.email".
+type Record struct {
+	Time    time.Time
+	Level   zapcore.Level
+	Channel string
+	Message string
+	Fields  map[string]any
+}
+
+// Processor changes the record or drops it by returning false
+type Processor interface {
+	Process(rec Record) (Record, bool)
+}
+
+type ProcessorFunc func(rec Record) (Record, bool)
+
+func (f ProcessorFunc) Process(rec Record) (Record, bool) {
+	return f(rec)
+}
+
+// Chain runs processors in order until one of them drops the record
+type Chain []Processor
+
+func (c Chain) Process(rec Record) (Record, bool) {
+	for _, p := range c {
+		var keep bool
+		if rec, keep = p.Process(rec); !keep {
+			return rec, false
+		}
+	}
+
+	return rec, true
+}
+
+// Get returns the field by path, MessageField is the record message
+func (rec *Record) Get(path string) (any, bool) {
+	if path == MessageField {
+		return rec.Message, true
+	}
+
+	parent, key := rec.parent(path, false)
+	if parent == nil {
+		return nil, false
+	}
+
+	v, ok := parent[key]
+
+	return v, ok
+}
+
+// Set sets the field by path, missing parent objects are created
+func (rec *Record) Set(path string, value any) {
+	if path == MessageField {
+		if s, ok := value.(string); ok {
+			rec.Message = s
+			return
+		}
+	}
+
+	if rec.Fields == nil {
+		rec.Fields = make(map[string]any)
+	}
+
+	if parent, key := rec.parent(path, true); parent != nil {
+		parent[key] = value
+	}
+}
+
+// Delete removes the field by path and returns its value
+func (rec *Record) Delete(path string) (any, bool) {
+	if path == MessageField {
+		v := rec.Message
+		rec.Message = ""
+
+		return v, true
+	}
+
+	parent, key := rec.parent(path, false)
+	if parent == nil {
+		return nil, false
+	}
+
+	v, ok := parent[key]
+	delete(parent, key)
+
+	return v, ok
+}
+
+// parent returns the object which contains the last path element, nil when it doesn't exist and create isn't set
+func (rec *Record) parent(path string, create bool) (map[string]any, string) {
+	obj := rec.Fields
+	for {
+		name, rest, nested := strings.Cut(path, ".")
+		if !nested {
+			return obj, name
+		}
+
+		child, ok := obj[name].(map[string]any)
+		if !ok {
+			if !create {
+				return nil, ""
+			}
+
+			child = make(map[string]any)
+			obj[name] = child
+		}
+
+		obj, path = child, rest
+	}
+}
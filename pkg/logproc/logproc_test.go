@@ -0,0 +1,173 @@
+package logproc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+func TestRecordPaths(t *testing.T) {
+	rec := Record{Message: "msg"}
+
+	rec.Set("context.user.email", "a@example.com")
+	v, ok := rec.Get("context.user.email")
+	assert.True(t, ok)
+	assert.Equal(t, "a@example.com", v)
+
+	v, ok = rec.Get(MessageField)
+	assert.True(t, ok)
+	assert.Equal(t, "msg", v)
+
+	_, ok = rec.Get("context.missing.key")
+	assert.False(t, ok)
+
+	v, ok = rec.Delete("context.user")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]any{"email": "a@example.com"}, v)
+	assert.Equal(t, map[string]any{"context": map[string]any{}}, rec.Fields)
+}
+
+func TestProcessors(t *testing.T) {
+	redactor, err := redact.New(redact.Options{
+		Detectors: []string{redact.DetectorEmail, redact.DetectorCard},
+		Rules:     map[string]string{"token": `tok_[a-z0-9]+`},
+	})
+	require.NoError(t, err)
+
+	chain, err := NewChain([]Config{
+		{Type: TypeExtract, Pattern: `user=(?P<user>\S+)`},
+		{Type: TypeRename, Rename: map[string]string{"extra.uid": "user_id"}},
+		{Type: TypeDrop, Fields: []string{"extra"}},
+		{Type: TypeRedact},
+	}, redactor)
+	require.NoError(t, err)
+
+	rec, keep := chain.Process(Record{
+		Message: "login user=bob mail bob@example.com",
+		Fields: map[string]any{
+			"extra": map[string]any{"uid": 42, "token": "x"},
+			"context": map[string]any{
+				"cards":  []any{"4111 1111 1111 1111", "4111 1111 1111 1112"},
+				"secret": "tok_abc123",
+			},
+		},
+	})
+	require.True(t, keep)
+
+	assert.Equal(t, "login user=bob mail [REDACTED]", rec.Message)
+	assert.Equal(t, map[string]any{
+		"user":    "bob",
+		"user_id": 42,
+		"context": map[string]any{
+			"cards":  []any{"[REDACTED]", "4111 1111 1111 1112"},
+			"secret": "[REDACTED]",
+		},
+	}, rec.Fields)
+
+	assert.NoError(t, testutil.CollectAndCompare(redactor, strings.NewReader(`
+# HELP phpfpm_redactions_total The number of redacted values by source and rule
+# TYPE phpfpm_redactions_total counter
+phpfpm_redactions_total{rule="card",source="log"} 1
+phpfpm_redactions_total{rule="email",source="log"} 1
+phpfpm_redactions_total{rule="token",source="log"} 1
+`)))
+}
+
+func TestSampleAndChannels(t *testing.T) {
+	p, err := NewProcessor(Config{Type: TypeSample, Every: 3, Channels: []string{"app"}}, nil)
+	require.NoError(t, err)
+
+	kept := 0
+	for i := 0; i < 9; i++ {
+		if _, keep := p.Process(Record{Channel: "app", Level: zapcore.InfoLevel}); keep {
+			kept++
+		}
+	}
+	assert.Equal(t, 3, kept)
+
+	_, keep := p.Process(Record{Channel: "app", Level: zapcore.ErrorLevel})
+	assert.True(t, keep)
+
+	for i := 0; i < 3; i++ {
+		_, keep = p.Process(Record{Channel: "php-fpm", Level: zapcore.InfoLevel})
+		assert.True(t, keep)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	configs, err := LoadConfig(`[{"type": "redact", "channels": ["app"]}, {"type": "sample", "every": 10}]`)
+	require.NoError(t, err)
+	assert.Equal(t, []Config{{Type: TypeRedact, Channels: []string{"app"}}, {Type: TypeSample, Every: 10}}, configs)
+
+	_, err = LoadConfig(`[{"type": "redact", "detectors": ["email"]}]`)
+	assert.Error(t, err)
+
+	_, err = NewChain([]Config{{Type: "unknown"}}, nil)
+	assert.EqualError(t, err, "unknown processor: unknown type")
+
+	_, err = NewChain([]Config{{Type: TypeRedact}}, nil)
+	assert.EqualError(t, err, "redact processor: redactor isn't set")
+}
+
+func TestCore(t *testing.T) {
+	var buf bytes.Buffer
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.TimeKey = ""
+	log := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encCfg), zapcore.AddSync(&buf), zapcore.DebugLevel))
+
+	dropDebug := ProcessorFunc(func(rec Record) (Record, bool) {
+		return rec, rec.Level > zapcore.DebugLevel
+	})
+	redactor, err := redact.New(redact.Options{
+		Detectors:   []string{redact.DetectorEmail},
+		Rules:       map[string]string{"secret": `secret`},
+		Replacement: "***",
+	})
+	require.NoError(t, err)
+
+	log = log.WithOptions(WrapCore(Chain{dropDebug, NewRedact(redactor)})).Named("app").With(zap.String("pod", "secret-pod"))
+	log.Debug("dropped")
+	log.Info("mail a@example.com", zap.String("z", "z"), zap.Error(errors.New("secret")), zap.Int("a", 1))
+
+	assert.Equal(t,
+		`{"level":"info","logger":"app","msg":"mail ***","pod":"***-pod","z":"z","error":"***","a":1}`+"\n",
+		buf.String(),
+	)
+}
+
+func TestLineWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	redactor, err := redact.New(redact.Options{Detectors: []string{redact.DetectorEmail}})
+	require.NoError(t, err)
+	dropHealth := ProcessorFunc(func(rec Record) (Record, bool) {
+		return rec, rec.Message != "health"
+	})
+	w := NewLineWriter(&buf, Chain{NewRedact(redactor), dropHealth}, "app")
+
+	for _, line := range []string{
+		"plain a@example.com\n",
+		`{"message":"json a@example.com","context":{"n":12345678901234567890,"html":"<b>"}}` + "\r\n",
+		"health\n",
+		`{"message":"health"}` + "\n",
+	} {
+		n, err := w.Write([]byte(line))
+		require.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+
+	assert.Equal(t,
+		"plain [REDACTED]\n"+
+			`{"context":{"html":"<b>","n":12345678901234567890},"message":"json [REDACTED]"}`+"\r\n",
+		buf.String(),
+	)
+}
@@ -0,0 +1,144 @@
+package logproc
+
+import (
+	"regexp"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+// RedactSource is the source label of redactions counted by the redact processor
+const RedactSource = "log"
+
+// Extract sets fields from named groups of pattern matched against the string field
+type Extract struct {
+	Field   string
+	Pattern *regexp.Regexp
+}
+
+func (p *Extract) Process(rec Record) (Record, bool) {
+	v, ok := rec.Get(p.Field)
+	s, isString := v.(string)
+	if !ok || !isString {
+		return rec, true
+	}
+
+	matches := p.Pattern.FindStringSubmatch(s)
+	if matches == nil {
+		return rec, true
+	}
+
+	for i, name := range p.Pattern.SubexpNames() {
+		if name != "" && i < len(matches) {
+			rec.Set(name, matches[i])
+		}
+	}
+
+	return rec, true
+}
+
+// Drop removes fields
+type Drop struct {
+	Fields []string
+}
+
+func (p *Drop) Process(rec Record) (Record, bool) {
+	for _, path := range p.Fields {
+		rec.Delete(path)
+	}
+
+	return rec, true
+}
+
+// Rename moves fields from key paths to value paths
+type Rename struct {
+	Fields map[string]string
+}
+
+func (p *Rename) Process(rec Record) (Record, bool) {
+	for from, to := range p.Fields {
+		if v, ok := rec.Delete(from); ok {
+			rec.Set(to, v)
+		}
+	}
+
+	return rec, true
+}
+
+// Redact replaces secrets found by the redactor in the message and all string fields, nested ones included
+type Redact struct {
+	Redactor *redact.Redactor
+}
+
+func NewRedact(redactor *redact.Redactor) *Redact {
+	return &Redact{Redactor: redactor}
+}
+
+func (p *Redact) redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return p.Redactor.String(RedactSource, v)
+	case map[string]any:
+		for key, value := range v {
+			v[key] = p.redactValue(value)
+		}
+	case []any:
+		for i := range v {
+			v[i] = p.redactValue(v[i])
+		}
+	}
+
+	return v
+}
+
+func (p *Redact) Process(rec Record) (Record, bool) {
+	rec.Message = p.Redactor.String(RedactSource, rec.Message)
+	for key, value := range rec.Fields {
+		rec.Fields[key] = p.redactValue(value)
+	}
+
+	return rec, true
+}
+
+// Sample keeps one of every Every records with level below Below per channel, other records are kept
+type Sample struct {
+	Every int
+	Below zapcore.Level
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewSample(every int, below zapcore.Level) *Sample {
+	return &Sample{Every: every, Below: below, counts: make(map[string]int)}
+}
+
+func (p *Sample) Process(rec Record) (Record, bool) {
+	if p.Every <= 1 || rec.Level >= p.Below {
+		return rec, true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := p.counts[rec.Channel]
+	p.counts[rec.Channel] = (n + 1) % p.Every
+
+	return rec, n == 0
+}
+
+// channelFilter runs the processor only for records of the channels
+type channelFilter struct {
+	channels  map[string]bool
+	processor Processor
+}
+
+func (f *channelFilter) Process(rec Record) (Record, bool) {
+	if !f.channels[rec.Channel] {
+		return rec, true
+	}
+
+	return f.processor.Process(rec)
+}
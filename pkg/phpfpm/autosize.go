@@ -0,0 +1,124 @@
+package phpfpm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/procfs"
+)
+
+var (
+	ErrNoMemoryLimit    = errors.New("memory isn't limited")
+	ErrNoWorkers        = errors.New("no fpm workers found")
+	ErrNotEnoughMemory  = errors.New("memory limit is less than reserved memory")
+	ErrNoWorkerEstimate = errors.New("worker memory estimate isn't set")
+)
+
+type AutosizeOptions struct {
+	// MemoryLimit is the container memory limit
+	MemoryLimit uint64
+	// ReservedMemory is kept for the master process, the wrapper and opcache shared memory
+	ReservedMemory uint64
+	// WorkerMemory is the memory used by one worker
+	WorkerMemory uint64
+	// MinChildren is the lower bound of pm.max_children of every pool, default is 1
+	MinChildren int
+}
+
+// ParseByteSize parses size in php.ini shorthand notation: 128M, 1G, 512K or bytes
+func ParseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := uint64(1)
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier = 1 << 10
+	case 'm', 'M':
+		multiplier = 1 << 20
+	case 'g', 'G':
+		multiplier = 1 << 30
+	}
+
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	return value * multiplier, nil
+}
+
+// Autosize computes pm.max_children of every pool. Memory left after the reserved part is split between
+// pools proportionally to their configured pm.max_children, pools without it get equal shares.
+func Autosize(pools []Pool, opts AutosizeOptions) (map[string]int, error) {
+	if opts.MemoryLimit == 0 {
+		return nil, ErrNoMemoryLimit
+	}
+
+	if opts.WorkerMemory == 0 {
+		return nil, ErrNoWorkerEstimate
+	}
+
+	if opts.MemoryLimit <= opts.ReservedMemory {
+		return nil, ErrNotEnoughMemory
+	}
+
+	if opts.MinChildren < 1 {
+		opts.MinChildren = 1
+	}
+
+	total := int((opts.MemoryLimit - opts.ReservedMemory) / opts.WorkerMemory)
+
+	weights := make([]int, len(pools))
+	weightSum := 0
+	for i, pool := range pools {
+		weights[i] = max(pool.MaxChildren, 1)
+		weightSum += weights[i]
+	}
+
+	result := make(map[string]int, len(pools))
+	for i, pool := range pools {
+		result[pool.Name] = max(total*weights[i]/weightSum, opts.MinChildren)
+	}
+
+	return result, nil
+}
+
+// MeasureWorkerMemory returns average proportional set size of the master workers and the number of measured
+// workers. PSS divides opcache and other shared pages between workers, so the sum of worker PSS is close to
+// the memory they really use. RSS is used when smaps aren't readable.
+func MeasureWorkerMemory(fs procfs.FS, masterPid int) (uint64, int, error) {
+	nodes, err := FindProcessTree(fs, masterPid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var sum uint64
+	count := 0
+	for _, node := range nodes {
+		if !node.Worker {
+			continue
+		}
+
+		if rollup, err := node.Proc.ProcSMapsRollup(); err == nil && rollup.Pss > 0 {
+			sum += rollup.Pss
+		} else {
+			sum += uint64(node.Stat.ResidentMemory())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, 0, ErrNoWorkers
+	}
+
+	return sum / uint64(count), count, nil
+}
@@ -0,0 +1,54 @@
+package phpfpm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for s, expected := range map[string]uint64{"": 0, "1024": 1024, "64K": 64 << 10, "128M": 128 << 20, "2g": 2 << 30} {
+		size, err := ParseByteSize(s)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, size, s)
+	}
+
+	_, err := ParseByteSize("12X")
+	assert.Error(t, err)
+}
+
+func TestAutosize(t *testing.T) {
+	pools := []Pool{{Name: "www", MaxChildren: 30}, {Name: "api", MaxChildren: 10}, {Name: "cron"}}
+
+	result, err := Autosize(pools, AutosizeOptions{
+		MemoryLimit:    2 << 30,
+		ReservedMemory: 256 << 20,
+		WorkerMemory:   64 << 20,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"www": 20, "api": 6, "cron": 1}, result)
+
+	_, err = Autosize(pools, AutosizeOptions{WorkerMemory: 64 << 20})
+	assert.ErrorIs(t, err, ErrNoMemoryLimit)
+}
+
+func TestConfigOverride_Render(t *testing.T) {
+	o := NewConfigOverride("/etc/php-fpm.conf")
+	o.SetPool("www", "pm.max_children", "21")
+	o.SetPool("api", "pm.max_children", "6")
+
+	var buf bytes.Buffer
+	assert.NoError(t, o.Render(&buf))
+	assert.Equal(t, `; generated by docker-fpm-wrapper, don't edit
+
+[global]
+include = /etc/php-fpm.conf
+
+[api]
+pm.max_children = 6
+
+[www]
+pm.max_children = 21
+`, buf.String())
+}
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"gopkg.in/ini.v1"
 )
@@ -22,11 +23,80 @@ type Pool struct {
 	Listen                   string
 	StatusPath               string
 	StatusListen             string
+	PingPath                 string
+	PM                       string
+	MaxChildren              int
+	MaxRequests              int
 	SlowlogPath              string
 	RequestSlowlogTimeout    int
 	RequestSlowlogTraceDepth int
 }
 
+// parseSeconds parses fpm time value, available units are s (default), m, h and d
+func parseSeconds(s string) int {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "s"):
+	case strings.HasSuffix(s, "m"):
+		multiplier = 60
+	case strings.HasSuffix(s, "h"):
+		multiplier = 60 * 60
+	case strings.HasSuffix(s, "d"):
+		multiplier = 24 * 60 * 60
+	default:
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	n, _ := strconv.Atoi(s[:len(s)-1])
+
+	return n * multiplier
+}
+
+func isDigitOnlyStr(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func listenToNetAndAddr(listen string) (string, string) {
+	network := "unix"
+	const localhost = "127.0.0.1"
+	semicolonPos := strings.IndexByte(listen, ':')
+
+	if semicolonPos != -1 {
+		network = "tcp"
+		if semicolonPos == 0 {
+			listen = localhost + listen
+		}
+	}
+
+	if isDigitOnlyStr(listen) {
+		network = "tcp"
+		listen = localhost + ":" + listen
+	}
+
+	return network, listen
+}
+
+// ListenAddr returns network and address the pool accepts FastCGI requests on
+func (p Pool) ListenAddr() (string, string) {
+	return listenToNetAndAddr(p.Listen)
+}
+
+// StatusAddr returns network and address the pool status page is served on
+func (p Pool) StatusAddr() (string, string) {
+	if p.StatusListen != "" {
+		return listenToNetAndAddr(p.StatusListen)
+	}
+
+	return listenToNetAndAddr(p.Listen)
+}
+
 func fillPull(config *Config, iniConfig *ini.File, poolName string) error {
 	pool := Pool{}
 	pool.Name = poolName
@@ -52,6 +122,26 @@ func fillPull(config *Config, iniConfig *ini.File, poolName string) error {
 		pool.StatusListen = key.String()
 	}
 
+	key, err = section.GetKey("ping.path")
+	if err == nil {
+		pool.PingPath = key.String()
+	}
+
+	key, err = section.GetKey("pm")
+	if err == nil {
+		pool.PM = key.String()
+	}
+
+	key, err = section.GetKey("pm.max_children")
+	if err == nil {
+		pool.MaxChildren, _ = strconv.Atoi(key.String())
+	}
+
+	key, err = section.GetKey("pm.max_requests")
+	if err == nil {
+		pool.MaxRequests, _ = strconv.Atoi(key.String())
+	}
+
 	key, err = section.GetKey("slowlog")
 	if err == nil {
 		pool.SlowlogPath = strings.Replace(key.String(), "$pool", poolName, 1)
@@ -59,7 +149,7 @@ func fillPull(config *Config, iniConfig *ini.File, poolName string) error {
 
 	key, err = section.GetKey("request_slowlog_timeout")
 	if err == nil {
-		pool.RequestSlowlogTimeout, _ = strconv.Atoi(key.String())
+		pool.RequestSlowlogTimeout = parseSeconds(key.String())
 	}
 
 	pool.RequestSlowlogTraceDepth = 64
@@ -72,50 +162,62 @@ func fillPull(config *Config, iniConfig *ini.File, poolName string) error {
 	return nil
 }
 
-func ParseConfig(fpmConfigPath string) (Config, error) {
-	c := Config{}
+func loadConfigIni(fpmConfigPath string) (*ini.File, string, error) {
 	cfg := ini.Empty()
 	err := cfg.Append(fpmConfigPath)
 	if err != nil {
-		return c, err
+		return nil, "", err
 	}
 
 	global, err := cfg.GetSection("global")
 	if err != nil {
-		return c, err
+		return nil, "", err
 	}
 
-	if key, err := global.GetKey("error_log"); err == nil {
-		c.ErrorLog = key.String()
+	include, err := global.GetKey("include")
+	if err != nil {
+		return cfg, "", nil
 	}
 
-	if include, err := global.GetKey("include"); err == nil {
-		c.Include = include.Value()
+	file := regexp.QuoteMeta(path.Base(include.Value()))
+	file = strings.Replace(file, regexp.QuoteMeta("*"), "(.+)", 1)
+	file = fmt.Sprintf("^%s$", file)
+	fileRx := regexp.MustCompile(file)
 
-		file := regexp.QuoteMeta(path.Base(c.Include))
-		file = strings.Replace(file, regexp.QuoteMeta("*"), "(.+)", 1)
-		file = fmt.Sprintf("^%s$", file)
-		fileRx := regexp.MustCompile(file)
+	fmpPoolsDir := path.Dir(include.Value())
+	osFileInfo, err := os.ReadDir(fmpPoolsDir)
+	if err != nil {
+		return nil, "", err
+	}
 
-		fmpPoolsDir := path.Dir(c.Include)
-		osFileInfo, err := os.ReadDir(fmpPoolsDir)
-		if err != nil {
-			return c, err
+	for _, info := range osFileInfo {
+		if info.IsDir() || !fileRx.MatchString(info.Name()) {
+			continue
 		}
 
-		for _, info := range osFileInfo {
-			if info.IsDir() || !fileRx.MatchString(info.Name()) {
-				continue
-			}
-
-			if err = cfg.Append(fmt.Sprintf("%s/%s", fmpPoolsDir, info.Name())); err != nil {
-				return c, err
-			}
+		if err = cfg.Append(fmt.Sprintf("%s/%s", fmpPoolsDir, info.Name())); err != nil {
+			return nil, "", err
 		}
 	}
 
+	return cfg, include.Value(), nil
+}
+
+func parseConfigIni(cfg *ini.File, include string) (Config, error) {
+	c := Config{Include: include}
+
+	global, err := cfg.GetSection("global")
+	if err != nil {
+		return c, err
+	}
+
+	if key, err := global.GetKey("error_log"); err == nil {
+		c.ErrorLog = key.String()
+	}
+
 	for _, section := range cfg.Sections() {
-		_, err = section.GetKey("pm.status_path")
+		// every pool must listen, global and default sections don't
+		_, err = section.GetKey("listen")
 		if err != nil {
 			continue
 		}
@@ -125,5 +227,43 @@ func ParseConfig(fpmConfigPath string) (Config, error) {
 		}
 	}
 
-	return c, err
+	return c, nil
+}
+
+func ParseConfig(fpmConfigPath string) (Config, error) {
+	cfg, include, err := loadConfigIni(fpmConfigPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return parseConfigIni(cfg, include)
+}
+
+// ParseConfigOverride parses the base config with applied override values, the base config is optional
+func ParseConfigOverride(o *ConfigOverride) (Config, error) {
+	cfg, include := ini.Empty(), ""
+	if o.BaseConfig != "" {
+		var err error
+		if cfg, include, err = loadConfigIni(o.BaseConfig); err != nil {
+			return Config{}, err
+		}
+	}
+
+	sections := map[string]map[string]string{"global": o.global}
+	for name, values := range o.pools {
+		sections[name] = values
+	}
+
+	for name, values := range sections {
+		section, err := cfg.NewSection(name)
+		if err != nil {
+			return Config{}, err
+		}
+
+		for key, value := range values {
+			section.Key(key).SetValue(value)
+		}
+	}
+
+	return parseConfigIni(cfg, include)
 }
@@ -24,4 +24,13 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, "/run/php-fpm/php-fpm.sock", c.Pools[0].Listen)
 	assert.Equal(t, "/status", c.Pools[0].StatusPath)
 	assert.Equal(t, "log/www.log.slow", c.Pools[0].SlowlogPath)
+	assert.Equal(t, "dynamic", c.Pools[0].PM)
+	assert.Equal(t, 5, c.Pools[0].MaxChildren)
+	assert.Equal(t, 0, c.Pools[0].MaxRequests)
+}
+
+func TestParseSeconds(t *testing.T) {
+	for s, expected := range map[string]int{"": 0, "0": 0, "5": 5, "5s": 5, "2m": 120, "1h": 3600, "1d": 86400, "x": 0} {
+		assert.Equal(t, expected, parseSeconds(s), s)
+	}
 }
@@ -26,6 +26,9 @@ func NewErrLogParser() *ErrLogParser {
 	return &ErrLogParser{}
 }
 
+// ErrUnexpectedLogLine is returned by ParseOne for a line which isn't an error log entry, the line is consumed
+var ErrUnexpectedLogLine = errors.New("unexpected log line format")
+
 var errLogEntryRegexp = regexp.MustCompile(`^\[([^]]+)]\s+(ALERT|ERROR|WARNING|NOTICE|DEBUG):\s+([^\n]+)\n$`)
 
 func (p *ErrLogParser) ParseOne(r *bufio.Reader) (ErrLogEntry, error) {
@@ -37,12 +40,12 @@ func (p *ErrLogParser) ParseOne(r *bufio.Reader) (ErrLogEntry, error) {
 
 	matches := errLogEntryRegexp.FindSubmatchIndex(buf)
 	if len(matches) == 0 {
-		return result, errors.New("unexpected log line format")
+		return result, ErrUnexpectedLogLine
 	}
 	//
 	result.CreatedAt, err = time.Parse("02-Jan-2006 15:04:05", string(buf[matches[2]:matches[3]]))
 	if err != nil {
-		return result, fmt.Errorf("can't parse timestamp: %w", err)
+		return result, fmt.Errorf("%w: can't parse timestamp: %v", ErrUnexpectedLogLine, err)
 	}
 
 	result.Level = LogLevel(buf[matches[4]:matches[5]])
@@ -0,0 +1,151 @@
+package phpfpm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// ErrOpenMetricsUnsupported is returned by php-fpm older than 8.1, which ignores ?openmetrics
+var ErrOpenMetricsUnsupported = errors.New("status page doesn't support openmetrics format")
+
+const openMetricsContentType = "application/openmetrics-text"
+
+func ParseOpenMetrics(r io.Reader) (map[string]*dto.MetricFamily, error) {
+	var parser expfmt.TextParser
+
+	return parser.TextToMetricFamilies(r)
+}
+
+// OpenMetrics requests status page in openmetrics format
+func (c *StatusClient) OpenMetrics(ctx context.Context) (map[string]*dto.MetricFamily, error) {
+	resp, err := c.Page(ctx, c.pool.StatusPath, "openmetrics")
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusPageError(resp)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != openMetricsContentType {
+		return nil, ErrOpenMetricsUnsupported
+	}
+
+	return ParseOpenMetrics(resp.Body)
+}
+
+// openMetricsRelabeler converts parsed metric families into const metrics with pool_name label.
+// Descs are shared between pools, so help strings stay consistent, php-fpm puts pool name into some of them.
+type openMetricsRelabeler struct {
+	mu    sync.Mutex
+	descs map[string]*prometheus.Desc
+}
+
+func newOpenMetricsRelabeler() *openMetricsRelabeler {
+	return &openMetricsRelabeler{descs: make(map[string]*prometheus.Desc)}
+}
+
+func (r *openMetricsRelabeler) getDesc(family *dto.MetricFamily, labelNames []string) *prometheus.Desc {
+	key := family.GetName() + "\xff" + strings.Join(labelNames, "\xff")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	desc, ok := r.descs[key]
+	if !ok {
+		desc = prometheus.NewDesc(family.GetName(), family.GetHelp(), append([]string{"pool_name"}, labelNames...), nil)
+		r.descs[key] = desc
+	}
+
+	return desc
+}
+
+func metricValue(family *dto.MetricFamily, metric *dto.Metric) (prometheus.ValueType, float64, bool) {
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		return prometheus.CounterValue, metric.GetCounter().GetValue(), true
+	case dto.MetricType_GAUGE:
+		return prometheus.GaugeValue, metric.GetGauge().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return prometheus.UntypedValue, metric.GetUntyped().GetValue(), true
+	default:
+		return 0, 0, false
+	}
+}
+
+// nativeUpFamily is replaced by phpfpm_up of the collector, which also reports failed polls
+const nativeUpFamily = "phpfpm_up"
+
+func (r *openMetricsRelabeler) Collect(poolName string, families map[string]*dto.MetricFamily, ch chan<- prometheus.Metric) {
+	for name, family := range families {
+		if name == nativeUpFamily {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			valueType, value, ok := metricValue(family, metric)
+			if !ok {
+				continue
+			}
+
+			labels := metric.GetLabel()
+			sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+
+			labelNames := make([]string, 0, len(labels))
+			labelValues := make([]string, 0, len(labels)+1)
+			labelValues = append(labelValues, poolName)
+			for _, label := range labels {
+				labelNames = append(labelNames, label.GetName())
+				labelValues = append(labelValues, label.GetValue())
+			}
+
+			m, err := prometheus.NewConstMetric(r.getDesc(family, labelNames), valueType, value, labelValues...)
+			if err != nil {
+				continue
+			}
+
+			ch <- m
+		}
+	}
+}
+
+func familyIntValue(families map[string]*dto.MetricFamily, name string) int {
+	family, ok := families[name]
+	if !ok || len(family.GetMetric()) == 0 {
+		return 0
+	}
+
+	_, value, _ := metricValue(family, family.GetMetric()[0])
+
+	return int(value)
+}
+
+// statusFromOpenMetrics fills pool summary fields of the status from openmetrics families
+func statusFromOpenMetrics(poolName string, families map[string]*dto.MetricFamily) *Status {
+	return &Status{
+		Name:               poolName,
+		StartSince:         familyIntValue(families, "phpfpm_start_since"),
+		AcceptedConn:       familyIntValue(families, "phpfpm_accepted_connections"),
+		ListenQueue:        familyIntValue(families, "phpfpm_listen_queue"),
+		MaxListenQueue:     familyIntValue(families, "phpfpm_max_listen_queue"),
+		ListenQueueLen:     familyIntValue(families, "phpfpm_listen_queue_length"),
+		IdleProcesses:      familyIntValue(families, "phpfpm_idle_processes"),
+		ActiveProcesses:    familyIntValue(families, "phpfpm_active_processes"),
+		TotalProcesses:     familyIntValue(families, "phpfpm_total_processes"),
+		MaxActiveProcesses: familyIntValue(families, "phpfpm_max_active_processes"),
+		MaxChildrenReached: familyIntValue(families, "phpfpm_max_children_reached"),
+		SlowRequests:       familyIntValue(families, "phpfpm_slow_requests"),
+	}
+}
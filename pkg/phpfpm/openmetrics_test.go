@@ -0,0 +1,47 @@
+package phpfpm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMetricsRelabeler_Collect(t *testing.T) {
+	f, err := os.Open("testdata/status_openmetrics.txt")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	families, err := ParseOpenMetrics(f)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Contains(t, families, "phpfpm_accepted_connections")
+	assert.Equal(t, dto.MetricType_COUNTER, families["phpfpm_accepted_connections"].GetType())
+
+	ch := make(chan prometheus.Metric, 128)
+	newOpenMetricsRelabeler().Collect("www", families, ch)
+	close(ch)
+
+	found := false
+	for m := range ch {
+		pb := &dto.Metric{}
+		if !assert.NoError(t, m.Write(pb)) {
+			return
+		}
+
+		assert.Equal(t, "pool_name", pb.GetLabel()[0].GetName())
+		assert.Equal(t, "www", pb.GetLabel()[0].GetValue())
+
+		if pb.GetCounter() != nil && pb.GetCounter().GetValue() == 1533 {
+			found = true
+		}
+	}
+
+	assert.True(t, found)
+}
@@ -0,0 +1,107 @@
+package phpfpm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ConfigOverride is a php-fpm config which includes the base config and overrides its values.
+// php-fpm merges sections with the same pool name, values declared later win.
+type ConfigOverride struct {
+	BaseConfig string
+
+	global map[string]string
+	pools  map[string]map[string]string
+}
+
+func NewConfigOverride(baseConfig string) *ConfigOverride {
+	return &ConfigOverride{
+		BaseConfig: baseConfig,
+		global:     make(map[string]string),
+		pools:      make(map[string]map[string]string),
+	}
+}
+
+func (o *ConfigOverride) SetGlobal(key, value string) {
+	o.global[key] = value
+}
+
+func (o *ConfigOverride) SetPool(pool, key, value string) {
+	if _, ok := o.pools[pool]; !ok {
+		o.pools[pool] = make(map[string]string)
+	}
+
+	o.pools[pool][key] = value
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func writeSection(w io.Writer, name string, values map[string]string) {
+	_, _ = fmt.Fprintf(w, "\n[%s]\n", name)
+	for _, key := range sortedKeys(values) {
+		_, _ = fmt.Fprintf(w, "%s = %s\n", key, values[key])
+	}
+}
+
+func (o *ConfigOverride) Render(w io.Writer) error {
+	var buf bytes.Buffer
+
+	buf.WriteString("; generated by docker-fpm-wrapper, don't edit\n")
+	if o.BaseConfig != "" {
+		global := map[string]string{"include": o.BaseConfig}
+		writeSection(&buf, "global", global)
+	}
+
+	if len(o.global) > 0 {
+		writeSection(&buf, "global", o.global)
+	}
+
+	for _, pool := range sortedKeys(o.pools) {
+		writeSection(&buf, pool, o.pools[pool])
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// WriteFile renders the config into a temp file and renames it, so php-fpm never reads a partial config
+func (o *ConfigOverride) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if err = o.Render(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
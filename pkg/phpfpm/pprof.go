@@ -0,0 +1,202 @@
+package phpfpm
+
+import (
+	"compress/gzip"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// pprof profile.proto field numbers, see https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	pprofProfileSampleType  = 1
+	pprofProfileSample      = 2
+	pprofProfileLocation    = 4
+	pprofProfileFunction    = 5
+	pprofProfileStringTable = 6
+	pprofProfileTimeNanos   = 9
+	pprofProfileDuration    = 10
+	pprofProfilePeriodType  = 11
+	pprofProfilePeriod      = 12
+
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+
+	pprofSampleLocationID = 1
+	pprofSampleValue      = 2
+	pprofSampleLabel      = 3
+
+	pprofLabelKey = 1
+	pprofLabelStr = 2
+
+	pprofLocationID   = 1
+	pprofLocationLine = 4
+
+	pprofLineFunctionID = 1
+	pprofLineLine       = 2
+
+	pprofFunctionID       = 1
+	pprofFunctionName     = 2
+	pprofFunctionFilename = 4
+)
+
+type pprofFunctionKey struct {
+	name, path string
+}
+
+type pprofLocationKey struct {
+	function uint64
+	line     int
+}
+
+// pprofBuilder encodes profile message, functions and locations are deduplicated
+type pprofBuilder struct {
+	buf []byte
+
+	strings   map[string]int64
+	functions map[pprofFunctionKey]uint64
+	locations map[pprofLocationKey]uint64
+}
+
+func newPprofBuilder() *pprofBuilder {
+	b := &pprofBuilder{
+		strings:   make(map[string]int64),
+		functions: make(map[pprofFunctionKey]uint64),
+		locations: make(map[pprofLocationKey]uint64),
+	}
+	// string_table[0] must be empty
+	b.str("")
+
+	return b
+}
+
+func (b *pprofBuilder) str(s string) int64 {
+	if idx, ok := b.strings[s]; ok {
+		return idx
+	}
+
+	idx := int64(len(b.strings))
+	b.strings[s] = idx
+
+	return idx
+}
+
+func (b *pprofBuilder) message(num protowire.Number, msg []byte) {
+	b.buf = protowire.AppendTag(b.buf, num, protowire.BytesType)
+	b.buf = protowire.AppendBytes(b.buf, msg)
+}
+
+func appendVarintField(buf []byte, num protowire.Number, v uint64) []byte {
+	buf = protowire.AppendTag(buf, num, protowire.VarintType)
+
+	return protowire.AppendVarint(buf, v)
+}
+
+func (b *pprofBuilder) valueType(num protowire.Number, typ, unit string) {
+	var msg []byte
+	msg = appendVarintField(msg, pprofValueTypeType, uint64(b.str(typ)))
+	msg = appendVarintField(msg, pprofValueTypeUnit, uint64(b.str(unit)))
+
+	b.message(num, msg)
+}
+
+func (b *pprofBuilder) function(name, path string) uint64 {
+	key := pprofFunctionKey{name: name, path: path}
+	if id, ok := b.functions[key]; ok {
+		return id
+	}
+
+	id := uint64(len(b.functions) + 1)
+	b.functions[key] = id
+
+	var msg []byte
+	msg = appendVarintField(msg, pprofFunctionID, id)
+	msg = appendVarintField(msg, pprofFunctionName, uint64(b.str(name)))
+	msg = appendVarintField(msg, pprofFunctionFilename, uint64(b.str(path)))
+	b.message(pprofProfileFunction, msg)
+
+	return id
+}
+
+func (b *pprofBuilder) location(frame SlowlogTraceEntry) uint64 {
+	key := pprofLocationKey{function: b.function(frame.FunName, frame.Path), line: frame.Line}
+	if id, ok := b.locations[key]; ok {
+		return id
+	}
+
+	id := uint64(len(b.locations) + 1)
+	b.locations[key] = id
+
+	var line []byte
+	line = appendVarintField(line, pprofLineFunctionID, key.function)
+	line = appendVarintField(line, pprofLineLine, uint64(frame.Line))
+
+	var msg []byte
+	msg = appendVarintField(msg, pprofLocationID, id)
+	msg = protowire.AppendTag(msg, pprofLocationLine, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, line)
+	b.message(pprofProfileLocation, msg)
+
+	return id
+}
+
+func (b *pprofBuilder) sample(sample SlowlogSample) {
+	var locations []byte
+	for _, frame := range sample.Frames {
+		locations = protowire.AppendVarint(locations, b.location(frame))
+	}
+
+	var label []byte
+	label = appendVarintField(label, pprofLabelKey, uint64(b.str("pool")))
+	label = appendVarintField(label, pprofLabelStr, uint64(b.str(sample.Pool)))
+
+	var msg []byte
+	msg = protowire.AppendTag(msg, pprofSampleLocationID, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, locations)
+	msg = protowire.AppendTag(msg, pprofSampleValue, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, protowire.AppendVarint(nil, 1))
+	msg = protowire.AppendTag(msg, pprofSampleLabel, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, label)
+	b.message(pprofProfileSample, msg)
+}
+
+func (b *pprofBuilder) stringTable() {
+	table := make([]string, len(b.strings))
+	for s, idx := range b.strings {
+		table[idx] = s
+	}
+
+	for _, s := range table {
+		b.buf = protowire.AppendTag(b.buf, pprofProfileStringTable, protowire.BytesType)
+		b.buf = protowire.AppendString(b.buf, s)
+	}
+}
+
+// WritePprof writes samples as gzipped pprof profile with "slow_requests" count value and "pool" label.
+// Every sample is one slow request, the first frame is the leaf as pprof expects.
+func WritePprof(w io.Writer, samples []SlowlogSample, now time.Time) error {
+	b := newPprofBuilder()
+	b.valueType(pprofProfileSampleType, "slow_requests", "count")
+
+	start := now
+	for i := range samples {
+		b.sample(samples[i])
+		if samples[i].Time.Before(start) {
+			start = samples[i].Time
+		}
+	}
+
+	b.buf = appendVarintField(b.buf, pprofProfileTimeNanos, uint64(start.UnixNano()))
+	b.buf = appendVarintField(b.buf, pprofProfileDuration, uint64(now.Sub(start)))
+	b.valueType(pprofProfilePeriodType, "slow_requests", "count")
+	b.buf = appendVarintField(b.buf, pprofProfilePeriod, 1)
+	b.stringTable()
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b.buf); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,218 @@
+package phpfpm
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"go.uber.org/zap"
+)
+
+const (
+	procStateMaster  = "master"
+	procStateChild   = "child"
+	procStateUnknown = "unknown"
+)
+
+// WorkerFinder looks up a worker in the last polled full status page. It's called for every worker on every scrape,
+// so it must not request the status page.
+type WorkerFinder interface {
+	FindProcess(pid int) (string, ProcessStatus, bool)
+}
+
+type procGroupKey struct {
+	pool  string
+	state string
+}
+
+type procGroup struct {
+	count   int
+	rss     float64
+	pss     float64
+	fds     float64
+	threads float64
+}
+
+type procPool struct {
+	cpu            float64
+	voluntary      float64
+	nonVoluntary   float64
+	maxWorkerRSS   float64
+	hasWorkerStats bool
+}
+
+// ProcCollector exports resources used by the fpm master and its descendants, read from procfs
+type ProcCollector struct {
+	log     *zap.Logger
+	fs      procfs.FS
+	pid     func() int
+	workers WorkerFinder
+
+	count        *prometheus.Desc
+	rss          *prometheus.Desc
+	pss          *prometheus.Desc
+	fds          *prometheus.Desc
+	threads      *prometheus.Desc
+	cpu          *prometheus.Desc
+	ctxSwitches  *prometheus.Desc
+	maxWorkerRSS *prometheus.Desc
+}
+
+// NewProcCollector creates the collector for the master process with pid returned by pid func,
+// workers is optional and is used to label workers with their state, e.g. StatusPoller with tracked processes.
+func NewProcCollector(log *zap.Logger, pid func() int, workers WorkerFinder) (*ProcCollector, error) {
+	fs, err := procfs.NewDefaultFS()
+	if err != nil {
+		return nil, err
+	}
+
+	stateLabels := []string{"pool_name", "state"}
+	poolLabels := []string{"pool_name"}
+
+	return &ProcCollector{
+		log:     log,
+		fs:      fs,
+		pid:     pid,
+		workers: workers,
+
+		count: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "count"),
+			"The number of fpm processes", stateLabels, nil,
+		),
+		rss: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "resident_memory_bytes"),
+			"Resident memory size of fpm processes", stateLabels, nil,
+		),
+		pss: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "proportional_memory_bytes"),
+			"Proportional set size of fpm processes, shared pages are divided between processes", stateLabels, nil,
+		),
+		fds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "open_fds"),
+			"The number of open file descriptors of fpm processes", stateLabels, nil,
+		),
+		threads: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "threads"),
+			"The number of threads of fpm processes", stateLabels, nil,
+		),
+		cpu: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "cpu_seconds"),
+			"User and system CPU time spent by fpm processes alive at scrape time, it drops when workers are recycled",
+			poolLabels, nil,
+		),
+		ctxSwitches: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "context_switches"),
+			"Context switches of fpm processes alive at scrape time, it drops when workers are recycled",
+			[]string{"pool_name", "type"}, nil,
+		),
+		maxWorkerRSS: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "max_worker_resident_memory_bytes"),
+			"Resident memory size of the biggest worker of the pool", poolLabels, nil,
+		),
+	}, nil
+}
+
+func (c *ProcCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.count
+	ch <- c.rss
+	ch <- c.pss
+	ch <- c.fds
+	ch <- c.threads
+	ch <- c.cpu
+	ch <- c.ctxSwitches
+	ch <- c.maxWorkerRSS
+}
+
+func (c *ProcCollector) nodeState(node ProcNode) string {
+	switch {
+	case node.Pool == "":
+		return procStateMaster
+	case !node.Worker:
+		return procStateChild
+	case c.workers == nil:
+		return procStateUnknown
+	}
+
+	_, proc, ok := c.workers.FindProcess(node.Proc.PID)
+	if !ok {
+		return procStateUnknown
+	}
+
+	return strings.ToLower(strings.ReplaceAll(proc.State, " ", "_"))
+}
+
+func (c *ProcCollector) Collect(ch chan<- prometheus.Metric) {
+	pid := c.pid()
+	if pid == 0 {
+		return
+	}
+
+	nodes, err := FindProcessTree(c.fs, pid)
+	if err != nil {
+		c.log.Error("can't read fpm process tree", zap.Error(err))
+		return
+	}
+
+	groups := make(map[procGroupKey]*procGroup)
+	pools := make(map[string]*procPool)
+
+	for _, node := range nodes {
+		key := procGroupKey{pool: node.Pool, state: c.nodeState(node)}
+		group, ok := groups[key]
+		if !ok {
+			group = &procGroup{}
+			groups[key] = group
+		}
+
+		pool, ok := pools[node.Pool]
+		if !ok {
+			pool = &procPool{}
+			pools[node.Pool] = pool
+		}
+
+		rss := float64(node.Stat.ResidentMemory())
+
+		group.count++
+		group.rss += rss
+		group.threads += float64(node.Stat.NumThreads)
+		pool.cpu += node.Stat.CPUTime()
+
+		if node.Worker {
+			pool.maxWorkerRSS = max(pool.maxWorkerRSS, rss)
+			pool.hasWorkerStats = true
+		}
+
+		// the process may exit at any moment, missing values are skipped
+		if fds, err := node.Proc.FileDescriptorsLen(); err == nil {
+			group.fds += float64(fds)
+		}
+
+		if rollup, err := node.Proc.ProcSMapsRollup(); err == nil {
+			group.pss += float64(rollup.Pss)
+		}
+
+		if status, err := node.Proc.NewStatus(); err == nil {
+			pool.voluntary += float64(status.VoluntaryCtxtSwitches)
+			pool.nonVoluntary += float64(status.NonVoluntaryCtxtSwitches)
+		}
+	}
+
+	for key, group := range groups {
+		ch <- prometheus.MustNewConstMetric(c.count, prometheus.GaugeValue, float64(group.count), key.pool, key.state)
+		ch <- prometheus.MustNewConstMetric(c.rss, prometheus.GaugeValue, group.rss, key.pool, key.state)
+		ch <- prometheus.MustNewConstMetric(c.pss, prometheus.GaugeValue, group.pss, key.pool, key.state)
+		ch <- prometheus.MustNewConstMetric(c.fds, prometheus.GaugeValue, group.fds, key.pool, key.state)
+		ch <- prometheus.MustNewConstMetric(c.threads, prometheus.GaugeValue, group.threads, key.pool, key.state)
+	}
+
+	for name, pool := range pools {
+		// sums over alive processes aren't monotonic, so they are gauges
+		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.GaugeValue, pool.cpu, name)
+		ch <- prometheus.MustNewConstMetric(c.ctxSwitches, prometheus.GaugeValue, pool.voluntary, name, "voluntary")
+		ch <- prometheus.MustNewConstMetric(c.ctxSwitches, prometheus.GaugeValue, pool.nonVoluntary, name, "nonvoluntary")
+
+		if pool.hasWorkerStats {
+			ch <- prometheus.MustNewConstMetric(c.maxWorkerRSS, prometheus.GaugeValue, pool.maxWorkerRSS, name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package phpfpm
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/procfs"
+)
+
+// fpm sets process title of workers to "php-fpm: pool <name>"
+var workerTitleRe = regexp.MustCompile(`^php-fpm[^:]*: pool (\S+)`)
+
+// ProcNode is a process of the php-fpm tree
+type ProcNode struct {
+	Proc procfs.Proc
+	Stat procfs.ProcStat
+	// Pool is empty for the master process
+	Pool string
+	// Worker is false for the master and for processes spawned by workers
+	Worker bool
+}
+
+func parseWorkerPool(cmdline []string) (string, bool) {
+	m := workerTitleRe.FindStringSubmatch(strings.TrimSpace(strings.Join(cmdline, " ")))
+	if m == nil {
+		return "", false
+	}
+
+	return m[1], true
+}
+
+// FindProcessTree returns the master process and all its descendants,
+// processes spawned by workers inherit the pool of the worker.
+func FindProcessTree(fs procfs.FS, masterPid int) ([]ProcNode, error) {
+	procs, err := fs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]ProcNode)
+	var master *ProcNode
+
+	for _, proc := range procs {
+		stat, err := proc.Stat()
+		if err != nil {
+			// the process has already exited
+			continue
+		}
+
+		node := ProcNode{Proc: proc, Stat: stat}
+		if proc.PID == masterPid {
+			master = &node
+			continue
+		}
+
+		children[stat.PPID] = append(children[stat.PPID], node)
+	}
+
+	if master == nil {
+		return nil, nil
+	}
+
+	result := []ProcNode{*master}
+	for i := 0; i < len(result); i++ {
+		parent := result[i]
+
+		for _, node := range children[parent.Proc.PID] {
+			node.Pool = parent.Pool
+
+			if parent.Proc.PID == masterPid {
+				if cmdline, err := node.Proc.CmdLine(); err == nil {
+					node.Pool, node.Worker = parseWorkerPool(cmdline)
+				}
+			}
+
+			result = append(result, node)
+		}
+	}
+
+	return result, nil
+}
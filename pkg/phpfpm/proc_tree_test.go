@@ -0,0 +1,60 @@
+package phpfpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFakeProc(t *testing.T, root string, pid, ppid int, cmdline string) {
+	dir := filepath.Join(root, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	stat := fmt.Sprintf("%d (php-fpm) S %d", pid, ppid) + strings.Repeat(" 0", 48) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline+"\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindProcessTree(t *testing.T) {
+	root := t.TempDir()
+	writeFakeProc(t, root, 1, 0, "docker-fpm-wrapper")
+	writeFakeProc(t, root, 10, 1, "php-fpm: master process (/etc/php-fpm.conf)")
+	writeFakeProc(t, root, 11, 10, "php-fpm: pool www        ")
+	writeFakeProc(t, root, 12, 10, "php-fpm: pool api")
+	writeFakeProc(t, root, 20, 12, "convert image.png")
+	writeFakeProc(t, root, 30, 1, "php-fpm: pool other")
+
+	fs, err := procfs.NewFS(root)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	nodes, err := FindProcessTree(fs, 10)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result := make(map[int]ProcNode)
+	for _, node := range nodes {
+		result[node.Proc.PID] = node
+	}
+
+	assert.Len(t, result, 4)
+	assert.Equal(t, "", result[10].Pool)
+	assert.Equal(t, ProcNode{Proc: result[11].Proc, Stat: result[11].Stat, Pool: "www", Worker: true}, result[11])
+	assert.Equal(t, "api", result[12].Pool)
+	assert.Equal(t, "api", result[20].Pool)
+	assert.False(t, result[20].Worker)
+}
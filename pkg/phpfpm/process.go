@@ -42,6 +42,20 @@ func (p *Process) Start() error {
 	return p.cmd.Start()
 }
 
+// Pid returns pid of the fpm master process, 0 if it wasn't started
+func (p *Process) Pid() int {
+	if p.cmd.Process == nil {
+		return 0
+	}
+
+	return p.cmd.Process.Pid
+}
+
+// Signal sends the signal to the fpm master process, SIGUSR2 reloads configuration gracefully
+func (p *Process) Signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
 func (p *Process) HandleSignal(signalCh chan os.Signal) {
 	for {
 		sig := <-signalCh
@@ -55,7 +69,7 @@ func (p *Process) HandleSignal(signalCh chan os.Signal) {
 			sig = syscall.SIGQUIT
 		}
 
-		if err := p.cmd.Process.Signal(sig); err != nil {
+		if err := p.Signal(sig); err != nil {
 			p.log.Error("Failed to send signal to process", zap.Stringer("signal", sig), zap.Error(err))
 		}
 	}
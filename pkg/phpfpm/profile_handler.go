@@ -0,0 +1,110 @@
+package phpfpm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultProfileDuration = 30 * time.Second
+
+// ProfileHandler samples stacks of busy workers of the pool on /fpm/{pool}/profile.
+// Only one profile runs at a time, concurrent requests get 409.
+type ProfileHandler struct {
+	mux         *http.ServeMux
+	clients     map[string]*StatusClient
+	sampler     StackSampler
+	maxDuration time.Duration
+
+	running sync.Mutex
+}
+
+func NewProfileHandler(clients []*StatusClient, sampler StackSampler, maxDuration time.Duration) *ProfileHandler {
+	h := &ProfileHandler{
+		mux:         http.NewServeMux(),
+		clients:     make(map[string]*StatusClient, len(clients)),
+		sampler:     sampler,
+		maxDuration: maxDuration,
+	}
+	for _, client := range clients {
+		h.clients[client.Pool().Name] = client
+	}
+
+	h.mux.HandleFunc("GET /fpm/{pool}/profile", h.serveProfile)
+
+	return h
+}
+
+func (h *ProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *ProfileHandler) duration(r *http.Request) (time.Duration, error) {
+	secondsStr := r.URL.Query().Get("seconds")
+	if secondsStr == "" {
+		return min(defaultProfileDuration, h.maxDuration), nil
+	}
+
+	seconds, err := strconv.Atoi(secondsStr)
+	if err != nil || seconds <= 0 {
+		return 0, errors.New("invalid seconds")
+	}
+
+	duration := time.Duration(seconds) * time.Second
+	if duration > h.maxDuration {
+		return 0, errors.New("seconds exceeds max profile duration " + h.maxDuration.String())
+	}
+
+	return duration, nil
+}
+
+// serveProfile writes sampled stacks in folded (default) or pprof format selected by "format" query parameter
+func (h *ProfileHandler) serveProfile(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.clients[r.PathValue("pool")]
+	if !ok {
+		http.Error(w, ErrUnknownPool.Error(), http.StatusNotFound)
+		return
+	}
+
+	duration, err := h.duration(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "folded" && format != "pprof" {
+		http.Error(w, "unknown format: "+format, http.StatusBadRequest)
+		return
+	}
+
+	if !h.running.TryLock() {
+		http.Error(w, "profile is already running", http.StatusConflict)
+		return
+	}
+	defer h.running.Unlock()
+
+	samples, err := h.sampler.Sample(r.Context(), client, duration)
+	if err != nil {
+		code := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+		}
+
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	switch format {
+	case "pprof":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="profile.pb.gz"`)
+		_ = WritePprof(w, samples, time.Now())
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = WriteFolded(w, samples)
+	}
+}
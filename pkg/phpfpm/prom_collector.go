@@ -1,124 +1,134 @@
 package phpfpm
 
 import (
-	"strings"
-	"sync"
-	"unicode"
+	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"go.uber.org/zap"
 )
 
+type MetricsMode string
+
+const (
+	// MetricsModeJSON decodes json status page into the fixed set of metrics
+	MetricsModeJSON MetricsMode = "json"
+	// MetricsModeOpenMetrics re-exposes native openmetrics status page (php 8.1+)
+	MetricsModeOpenMetrics MetricsMode = "openmetrics"
+	// MetricsModeAuto uses openmetrics and falls back to json when the status page doesn't support it
+	MetricsModeAuto MetricsMode = "auto"
+)
+
+func ParseMetricsMode(s string) (MetricsMode, error) {
+	switch mode := MetricsMode(s); mode {
+	case MetricsModeJSON, MetricsModeOpenMetrics, MetricsModeAuto:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown metrics mode: %s", s)
+	}
+}
+
 type PromCollector struct {
-	log     *zap.Logger
 	metrics *PromMetrics
-	pools   []Pool
+	poller  *StatusPoller
+
+	relabeler *openMetricsRelabeler
 }
 
-func NewPromCollector(log *zap.Logger, metrics *PromMetrics, pools []Pool) *PromCollector {
+func NewPromCollector(metrics *PromMetrics, poller *StatusPoller) *PromCollector {
 	return &PromCollector{
-		log:     log,
-		metrics: metrics,
-		pools:   pools,
+		metrics:   metrics,
+		poller:    poller,
+		relabeler: newOpenMetricsRelabeler(),
 	}
 }
 
 func (c *PromCollector) Describe(descs chan<- *prometheus.Desc) {
-	c.metrics.ListenQueue.Describe(descs)
-	c.metrics.ListenQueueLen.Describe(descs)
-	c.metrics.IdleProcesses.Describe(descs)
-	c.metrics.ActiveProcesses.Describe(descs)
-	c.metrics.TotalProcesses.Describe(descs)
-	c.metrics.AcceptedConn.Describe(descs)
-
-	c.metrics.StartSince.Describe(descs)
-	c.metrics.MaxListenQueue.Describe(descs)
-	c.metrics.MaxActiveProcesses.Describe(descs)
-	c.metrics.MaxChildrenReached.Describe(descs)
-	c.metrics.SlowRequests.Describe(descs)
-}
+	if c.poller.Mode() != MetricsModeJSON {
+		// openmetrics families are known only after scrape, so the collector is unchecked
+		return
+	}
 
-func (c *PromCollector) setAndCollect(gaugeVec *prometheus.GaugeVec, poolName string, val int, ch chan<- prometheus.Metric) {
-	gauge := gaugeVec.WithLabelValues(poolName)
-	gauge.Set(float64(val))
-	gauge.Collect(ch)
+	c.metrics.Describe(descs)
 }
 
-func (c *PromCollector) getStatusListenPath(pool Pool) string {
-	if pool.StatusListen != "" {
-		return pool.StatusListen
-	}
-
-	return pool.Listen
+func (c *PromCollector) collectValue(
+	ch chan<- prometheus.Metric,
+	desc *prometheus.Desc,
+	valueType prometheus.ValueType,
+	poolName string,
+	val float64,
+) {
+	ch <- prometheus.MustNewConstMetric(desc, valueType, val, poolName)
 }
 
-func (c *PromCollector) isDigitOnlyStr(s string) bool {
-	for _, r := range s {
-		if !unicode.IsDigit(r) {
-			return false
-		}
+func scrapeErrorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrStatusNotFound):
+		return "not_found"
+	case errors.Is(err, ErrStatusAccessDenied):
+		return "access_denied"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
 	}
+}
 
-	return true
+func (c *PromCollector) collectStatus(status *Status, ch chan<- prometheus.Metric) {
+	m, pool := c.metrics, status.Name
+
+	c.collectValue(ch, m.ListenQueue, prometheus.GaugeValue, pool, float64(status.ListenQueue))
+	c.collectValue(ch, m.ListenQueueLen, prometheus.GaugeValue, pool, float64(status.ListenQueueLen))
+	c.collectValue(ch, m.IdleProcesses, prometheus.GaugeValue, pool, float64(status.IdleProcesses))
+	c.collectValue(ch, m.ActiveProcesses, prometheus.GaugeValue, pool, float64(status.ActiveProcesses))
+	c.collectValue(ch, m.TotalProcesses, prometheus.GaugeValue, pool, float64(status.TotalProcesses))
+	c.collectValue(ch, m.AcceptedConn, prometheus.CounterValue, pool, float64(status.AcceptedConn))
+	c.collectValue(ch, m.StartSince, prometheus.GaugeValue, pool, float64(status.StartSince))
+	c.collectValue(ch, m.MaxListenQueue, prometheus.GaugeValue, pool, float64(status.MaxListenQueue))
+	c.collectValue(ch, m.MaxActiveProcesses, prometheus.GaugeValue, pool, float64(status.MaxActiveProcesses))
+	c.collectValue(ch, m.MaxChildrenReached, prometheus.CounterValue, pool, float64(status.MaxChildrenReached))
+	c.collectValue(ch, m.SlowRequests, prometheus.CounterValue, pool, float64(status.SlowRequests))
 }
 
-func (c *PromCollector) listenToNetAndAddr(listen string) (string, string) {
-	network := "unix"
-	const localhost = "127.0.0.1"
-	semicolonPos := strings.IndexByte(listen, ':')
+func (c *PromCollector) collectSnapshot(snapshot PoolSnapshot, ch chan<- prometheus.Metric) {
+	m, pool := c.metrics, snapshot.Pool
 
-	if semicolonPos != -1 {
-		network = "tcp"
-		if semicolonPos == 0 {
-			listen = localhost + listen
-		}
+	up := 0.0
+	if snapshot.Up {
+		up = 1
 	}
+	c.collectValue(ch, m.Up, prometheus.GaugeValue, pool, up)
+	c.collectValue(ch, m.ScrapeDuration, prometheus.GaugeValue, pool, snapshot.ScrapeDuration.Seconds())
 
-	if c.isDigitOnlyStr(listen) {
-		network = "tcp"
-		listen = localhost + ":" + listen
+	if !snapshot.HasSample {
+		return
 	}
 
-	return network, listen
-}
+	c.collectValue(ch, m.SampleAge, prometheus.GaugeValue, pool, time.Since(snapshot.Last.Time).Seconds())
 
-func (c *PromCollector) collectForPool(pool Pool, ch chan<- prometheus.Metric) {
-	net, addr := c.listenToNetAndAddr(c.getStatusListenPath(pool))
-	status, err := GetStats(net, addr, pool.StatusPath)
-	if err != nil {
-		c.log.Error("can't collect metrics", zap.String("pool", pool.Name), zap.Error(err))
+	if !snapshot.Up {
+		// the sample is stale, pool metrics are dropped until the next successful poll
 		return
 	}
 
-	c.setAndCollect(c.metrics.ListenQueue, status.Name, status.ListenQueue, ch)
-	c.setAndCollect(c.metrics.ListenQueueLen, status.Name, status.ListenQueueLen, ch)
-	c.setAndCollect(c.metrics.IdleProcesses, status.Name, status.IdleProcesses, ch)
-	c.setAndCollect(c.metrics.ActiveProcesses, status.Name, status.ActiveProcesses, ch)
-	c.setAndCollect(c.metrics.TotalProcesses, status.Name, status.TotalProcesses, ch)
-	c.setAndCollect(c.metrics.AcceptedConn, status.Name, status.AcceptedConn, ch)
-	c.setAndCollect(c.metrics.StartSince, status.Name, status.StartSince, ch)
-	c.setAndCollect(c.metrics.MaxListenQueue, status.Name, status.MaxListenQueue, ch)
-	c.setAndCollect(c.metrics.MaxActiveProcesses, status.Name, status.MaxActiveProcesses, ch)
-	c.setAndCollect(c.metrics.MaxChildrenReached, status.Name, status.MaxChildrenReached, ch)
-	c.setAndCollect(c.metrics.SlowRequests, status.Name, status.SlowRequests, ch)
-}
-
-func (c *PromCollector) Collect(metrics chan<- prometheus.Metric) {
-	var wg sync.WaitGroup
-	for pIdx := range c.pools {
-		pool := c.pools[pIdx]
-
-		if pool.StatusPath == "" {
-			continue
-		}
+	if snapshot.Last.Families != nil {
+		c.relabeler.Collect(pool, snapshot.Last.Families, ch)
+	} else {
+		c.collectStatus(snapshot.Last.Status, ch)
+	}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	c.collectValue(ch, m.PeakActiveProcesses, prometheus.GaugeValue, pool, float64(snapshot.PeakActiveProcesses))
+	if snapshot.HasRequestRate {
+		c.collectValue(ch, m.RequestRate, prometheus.GaugeValue, pool, snapshot.RequestRate)
+	}
+}
 
-			c.collectForPool(pool, metrics)
-		}()
+func (c *PromCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, snapshot := range c.poller.Snapshots() {
+		c.collectSnapshot(snapshot, ch)
 	}
 
-	wg.Wait()
+	c.metrics.ScrapeErrors.Collect(ch)
 }
@@ -0,0 +1,48 @@
+package phpfpm
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/fcgi"
+)
+
+func TestPromCollector_Collect(t *testing.T) {
+	pool := Pool{Name: "www", Listen: filepath.Join(t.TempDir(), "missing.sock"), StatusPath: "/status"}
+	metrics := NewPromMetrics()
+	poller := NewStatusPoller(zap.NewNop(), metrics, []*StatusClient{NewStatusClient(pool, fcgi.Options{})}, MetricsModeJSON, time.Second, 2)
+	collector := NewPromCollector(metrics, poller)
+
+	poller.Poll(context.Background())
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP phpfpm_up Whether the last status page poll of the pool was successful
+# TYPE phpfpm_up gauge
+phpfpm_up{pool_name="www"} 0
+`), "phpfpm_up", "phpfpm_accepted_conn")
+	assert.NoError(t, err)
+
+	pp := poller.pools[0]
+	pp.lastErr = nil
+	pp.history.Add(StatusSample{Time: time.Now(), Status: &Status{Name: "www", AcceptedConn: 42, ActiveProcesses: 3}})
+
+	err = testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP phpfpm_up Whether the last status page poll of the pool was successful
+# TYPE phpfpm_up gauge
+phpfpm_up{pool_name="www"} 1
+# HELP phpfpm_accepted_conn The number of requests accepted by the pool
+# TYPE phpfpm_accepted_conn counter
+phpfpm_accepted_conn{pool_name="www"} 42
+# HELP phpfpm_peak_active_processes The maximum number of active processes over the kept status samples
+# TYPE phpfpm_peak_active_processes gauge
+phpfpm_peak_active_processes{pool_name="www"} 3
+`), "phpfpm_up", "phpfpm_accepted_conn", "phpfpm_peak_active_processes")
+	assert.NoError(t, err)
+}
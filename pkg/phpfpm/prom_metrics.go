@@ -7,111 +7,120 @@ import (
 const namespace = "phpfpm"
 
 type PromMetrics struct {
-	ListenQueue     *prometheus.GaugeVec
-	ListenQueueLen  *prometheus.GaugeVec
-	IdleProcesses   *prometheus.GaugeVec
-	ActiveProcesses *prometheus.GaugeVec
-	TotalProcesses  *prometheus.GaugeVec
-	AcceptedConn    *prometheus.GaugeVec
+	Up             *prometheus.Desc
+	ScrapeDuration *prometheus.Desc
 
-	StartSince         *prometheus.GaugeVec
-	MaxListenQueue     *prometheus.GaugeVec
-	MaxActiveProcesses *prometheus.GaugeVec
-	MaxChildrenReached *prometheus.GaugeVec
-	SlowRequests       *prometheus.GaugeVec
+	ListenQueue     *prometheus.Desc
+	ListenQueueLen  *prometheus.Desc
+	IdleProcesses   *prometheus.Desc
+	ActiveProcesses *prometheus.Desc
+	TotalProcesses  *prometheus.Desc
+	AcceptedConn    *prometheus.Desc
+
+	StartSince         *prometheus.Desc
+	MaxListenQueue     *prometheus.Desc
+	MaxActiveProcesses *prometheus.Desc
+	MaxChildrenReached *prometheus.Desc
+	SlowRequests       *prometheus.Desc
+
+	SampleAge           *prometheus.Desc
+	RequestRate         *prometheus.Desc
+	PeakActiveProcesses *prometheus.Desc
+
+	ScrapeErrors *prometheus.CounterVec
 }
 
-func NewPromMetrics() *PromMetrics {
-	poolLabelNames := []string{"pool_name"}
+func newPoolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, []string{"pool_name"}, nil)
+}
 
+func NewPromMetrics() *PromMetrics {
 	return &PromMetrics{
-		StartSince: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "start_since",
-				Help:      "Number of seconds since FPM has started",
-			},
-			poolLabelNames,
+		Up: newPoolDesc(
+			"up",
+			"Whether the last status page poll of the pool was successful",
 		),
-		AcceptedConn: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "accepted_conn",
-				Help:      "The number of requests accepted by the pool",
-			},
-			poolLabelNames,
+		ScrapeDuration: newPoolDesc(
+			"scrape_duration_seconds",
+			"Duration of the last status page poll",
 		),
-		ListenQueue: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "listen_queue",
-				Help:      "The number of requests in the queue of pending connections",
-			},
-			poolLabelNames,
+		StartSince: newPoolDesc(
+			"start_since",
+			"Number of seconds since FPM has started",
 		),
-		MaxListenQueue: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "max_listen_queue",
-				Help:      "The maximum number of requests in the queue of pending connections since FPM has started",
-			},
-			poolLabelNames,
+		AcceptedConn: newPoolDesc(
+			"accepted_conn",
+			"The number of requests accepted by the pool",
 		),
-		ListenQueueLen: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "listen_queue_len",
-				Help:      "The size of the socket queue of pending connections",
-			},
-			poolLabelNames,
+		ListenQueue: newPoolDesc(
+			"listen_queue",
+			"The number of requests in the queue of pending connections",
 		),
-		IdleProcesses: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "idle_processes",
-				Help:      "The number of idle processes",
-			},
-			poolLabelNames,
+		MaxListenQueue: newPoolDesc(
+			"max_listen_queue",
+			"The maximum number of requests in the queue of pending connections since FPM has started",
 		),
-		ActiveProcesses: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "active_processes",
-				Help:      "The number of active processes",
-			},
-			poolLabelNames,
+		ListenQueueLen: newPoolDesc(
+			"listen_queue_len",
+			"The size of the socket queue of pending connections",
 		),
-		TotalProcesses: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "total_processes",
-				Help:      "The number of idle + active processes",
-			},
-			poolLabelNames,
+		IdleProcesses: newPoolDesc(
+			"idle_processes",
+			"The number of idle processes",
 		),
-		MaxActiveProcesses: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "max_active_processes",
-				Help:      "The maximum number of active processes since FPM has started",
-			},
-			poolLabelNames,
+		ActiveProcesses: newPoolDesc(
+			"active_processes",
+			"The number of active processes",
 		),
-		MaxChildrenReached: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "max_children_reached",
-				Help:      "The number of times, the process limit has been reached, when pm tries to start more children (works only for pm 'dynamic' and 'ondemand')",
-			},
-			poolLabelNames,
+		TotalProcesses: newPoolDesc(
+			"total_processes",
+			"The number of idle + active processes",
+		),
+		MaxActiveProcesses: newPoolDesc(
+			"max_active_processes",
+			"The maximum number of active processes since FPM has started",
 		),
-		SlowRequests: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
+		MaxChildrenReached: newPoolDesc(
+			"max_children_reached",
+			"The number of times, the process limit has been reached, when pm tries to start more children (works only for pm 'dynamic' and 'ondemand')",
+		),
+		SlowRequests: newPoolDesc(
+			"slow_requests",
+			"The number of requests that exceeded your request_slowlog_timeout value",
+		),
+		SampleAge: newPoolDesc(
+			"status_sample_age_seconds",
+			"Number of seconds since the last successful status page poll",
+		),
+		RequestRate: newPoolDesc(
+			"request_rate",
+			"Accepted requests per second over the kept status samples",
+		),
+		PeakActiveProcesses: newPoolDesc(
+			"peak_active_processes",
+			"The maximum number of active processes over the kept status samples",
+		),
+		ScrapeErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
 				Namespace: namespace,
-				Name:      "slow_requests",
-				Help:      "The number of requests that exceeded your request_slowlog_timeout value",
+				Name:      "scrape_errors_total",
+				Help:      "The number of failed status page polls by reason",
 			},
-			poolLabelNames,
+			[]string{"pool_name", "reason"},
 		),
 	}
 }
+
+// Describe sends descs of the json mode metrics
+func (m *PromMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		m.Up, m.ScrapeDuration,
+		m.ListenQueue, m.ListenQueueLen, m.IdleProcesses, m.ActiveProcesses, m.TotalProcesses, m.AcceptedConn,
+		m.StartSince, m.MaxListenQueue, m.MaxActiveProcesses, m.MaxChildrenReached, m.SlowRequests,
+		m.SampleAge, m.RequestRate, m.PeakActiveProcesses,
+	} {
+		ch <- desc
+	}
+
+	m.ScrapeErrors.Describe(ch)
+}
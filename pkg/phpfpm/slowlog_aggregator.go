@@ -0,0 +1,364 @@
+package phpfpm
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	slowlogExamplePids = 3
+	minSlowlogBucket   = time.Second
+)
+
+var DefaultSlowlogWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// SlowlogFrame is a trace frame of the stack signature, pointers and lines are ignored
+type SlowlogFrame struct {
+	Func string `json:"func"`
+	Path string `json:"path"`
+}
+
+// SlowlogStackStat is the stat of one slow call path
+type SlowlogStackStat struct {
+	Signature string         `json:"signature"`
+	Script    string         `json:"script"`
+	Frames    []SlowlogFrame `json:"frames"`
+	// Counts are keyed by window, e.g. "1m"
+	Counts      map[string]int `json:"counts"`
+	Total       int            `json:"total"`
+	LastSeen    time.Time      `json:"last_seen"`
+	Pools       []string       `json:"pools"`
+	ExamplePids []int          `json:"example_pids"`
+}
+
+// SlowlogReport is the top of slow call paths sorted by count over Window
+type SlowlogReport struct {
+	Window  string             `json:"window"`
+	Windows []string           `json:"windows"`
+	Stacks  []SlowlogStackStat `json:"stacks"`
+	// Dropped is the number of entries not counted because MaxStacks signatures were already tracked
+	Dropped int `json:"dropped"`
+}
+
+type slowlogStack struct {
+	script   string
+	frames   []SlowlogFrame
+	total    int
+	lastSeen time.Time
+	pools    []string
+	pids     []int
+}
+
+type slowlogBucket struct {
+	index  int64
+	counts map[uint64]int
+}
+
+// SlowlogAggregator groups slowlog entries by stack signature and counts them over sliding windows.
+// Counts are kept in a ring of buckets, a window is the sum of its last buckets.
+type SlowlogAggregator struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	windows   []time.Duration
+	bucket    time.Duration
+	buckets   []slowlogBucket
+	stacks    map[uint64]*slowlogStack
+	maxStacks int
+
+	added   int
+	dropped int
+	// latest is the time of the newest entry, it's used as now by replay
+	latest time.Time
+	replay bool
+}
+
+// NewSlowlogAggregator creates aggregator with windows, DefaultSlowlogWindows when empty.
+// At most maxStacks signatures are tracked, signatures not seen during the longest window are forgotten.
+func NewSlowlogAggregator(windows []time.Duration, maxStacks int) *SlowlogAggregator {
+	windows = slices.DeleteFunc(slices.Clone(windows), func(w time.Duration) bool { return w <= 0 })
+	if len(windows) == 0 {
+		windows = slices.Clone(DefaultSlowlogWindows)
+	}
+	slices.Sort(windows)
+	windows = slices.Compact(windows)
+
+	bucket := max(windows[0]/10, minSlowlogBucket)
+	bucketCount := int((windows[len(windows)-1] + bucket - 1) / bucket)
+
+	a := &SlowlogAggregator{
+		now:       time.Now,
+		windows:   windows,
+		bucket:    bucket,
+		buckets:   make([]slowlogBucket, bucketCount),
+		stacks:    make(map[uint64]*slowlogStack),
+		maxStacks: maxStacks,
+	}
+	for i := range a.buckets {
+		a.buckets[i] = slowlogBucket{index: -1, counts: make(map[uint64]int)}
+	}
+
+	return a
+}
+
+// SlowlogSignature returns hash of the script and function+file sequence of the trace
+func SlowlogSignature(entry SlowlogEntry) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(entry.ScriptFilename))
+
+	for i := range entry.Stacktrace {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(entry.Stacktrace[i].FunName))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(entry.Stacktrace[i].Path))
+	}
+
+	return h.Sum64()
+}
+
+func formatSignature(sig uint64) string {
+	return strconv.FormatUint(sig, 16)
+}
+
+func formatWindow(w time.Duration) string {
+	switch {
+	case w%time.Hour == 0:
+		return strconv.FormatInt(int64(w/time.Hour), 10) + "h"
+	case w%time.Minute == 0:
+		return strconv.FormatInt(int64(w/time.Minute), 10) + "m"
+	case w%time.Second == 0:
+		return strconv.FormatInt(int64(w/time.Second), 10) + "s"
+	default:
+		return w.String()
+	}
+}
+
+// Replay makes the aggregator count windows back from the newest entry instead of the current time,
+// so the top of an old slowlog file isn't empty
+func (a *SlowlogAggregator) Replay() {
+	a.mu.Lock()
+	a.replay = true
+	a.mu.Unlock()
+}
+
+func (a *SlowlogAggregator) current() time.Time {
+	if a.replay {
+		return a.latest
+	}
+
+	return a.now()
+}
+
+func (a *SlowlogAggregator) bucketIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(a.bucket)
+}
+
+func (a *SlowlogAggregator) windowBuckets(w time.Duration) int64 {
+	return int64((w + a.bucket - 1) / a.bucket)
+}
+
+// expire forgets signatures which weren't seen during the longest window
+func (a *SlowlogAggregator) expire(now time.Time) {
+	deadline := now.Add(-a.windows[len(a.windows)-1])
+	for sig, stack := range a.stacks {
+		if stack.lastSeen.Before(deadline) {
+			delete(a.stacks, sig)
+		}
+	}
+}
+
+func newSlowlogStack(entry SlowlogEntry) *slowlogStack {
+	stack := &slowlogStack{script: entry.ScriptFilename, frames: make([]SlowlogFrame, len(entry.Stacktrace))}
+	for i := range entry.Stacktrace {
+		stack.frames[i] = SlowlogFrame{Func: entry.Stacktrace[i].FunName, Path: entry.Stacktrace[i].Path}
+	}
+
+	return stack
+}
+
+func (a *SlowlogAggregator) Add(entry SlowlogEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// entries are bucketed by their own time, so replayed entries don't fall into the current window
+	now := entry.CreatedAt
+	if now.IsZero() {
+		now = a.now()
+	}
+	if now.After(a.latest) {
+		a.latest = now
+	}
+
+	sig := SlowlogSignature(entry)
+	a.added++
+
+	stack, ok := a.stacks[sig]
+	if !ok {
+		if a.maxStacks > 0 && len(a.stacks) >= a.maxStacks {
+			a.expire(now)
+		}
+
+		if a.maxStacks > 0 && len(a.stacks) >= a.maxStacks {
+			a.dropped++
+			return
+		}
+
+		stack = newSlowlogStack(entry)
+		a.stacks[sig] = stack
+	}
+
+	stack.total++
+	if now.After(stack.lastSeen) {
+		stack.lastSeen = now
+	}
+	if !slices.Contains(stack.pools, entry.PoolName) {
+		stack.pools = append(stack.pools, entry.PoolName)
+	}
+
+	stack.pids = append(stack.pids, entry.Pid)
+	if len(stack.pids) > slowlogExamplePids {
+		stack.pids = stack.pids[len(stack.pids)-slowlogExamplePids:]
+	}
+
+	index := a.bucketIndex(now)
+	bucket := &a.buckets[index%int64(len(a.buckets))]
+	if bucket.index > index {
+		// the entry is older than the longest window kept by buckets
+		return
+	}
+	if bucket.index != index {
+		bucket.index = index
+		clear(bucket.counts)
+	}
+	bucket.counts[sig]++
+}
+
+// Added returns the number of entries added since start
+func (a *SlowlogAggregator) Added() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.added
+}
+
+func (a *SlowlogAggregator) Windows() []time.Duration {
+	return slices.Clone(a.windows)
+}
+
+// Top returns n slow call paths with the biggest count over window, the longest window is used
+// when window isn't one of the aggregator windows. n <= 0 returns all of them.
+func (a *SlowlogAggregator) Top(n int, window time.Duration) SlowlogReport {
+	if !slices.Contains(a.windows, window) {
+		window = a.windows[len(a.windows)-1]
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report := SlowlogReport{Window: formatWindow(window), Stacks: []SlowlogStackStat{}, Dropped: a.dropped}
+	for _, w := range a.windows {
+		report.Windows = append(report.Windows, formatWindow(w))
+	}
+
+	nowIndex := a.bucketIndex(a.current())
+	counts := make(map[uint64][]int, len(a.stacks))
+	for i := range a.buckets {
+		age := nowIndex - a.buckets[i].index
+		if a.buckets[i].index < 0 || age < 0 {
+			continue
+		}
+
+		for sig, count := range a.buckets[i].counts {
+			sigCounts, ok := counts[sig]
+			if !ok {
+				sigCounts = make([]int, len(a.windows))
+				counts[sig] = sigCounts
+			}
+
+			for wi, w := range a.windows {
+				if age < a.windowBuckets(w) {
+					sigCounts[wi] += count
+				}
+			}
+		}
+	}
+
+	sortIndex := slices.Index(a.windows, window)
+	for sig, sigCounts := range counts {
+		stack, ok := a.stacks[sig]
+		if !ok || sigCounts[sortIndex] == 0 {
+			continue
+		}
+
+		stat := SlowlogStackStat{
+			Signature:   formatSignature(sig),
+			Script:      stack.script,
+			Frames:      stack.frames,
+			Counts:      make(map[string]int, len(a.windows)),
+			Total:       stack.total,
+			LastSeen:    stack.lastSeen,
+			Pools:       slices.Clone(stack.pools),
+			ExamplePids: slices.Clone(stack.pids),
+		}
+		for wi, w := range a.windows {
+			stat.Counts[formatWindow(w)] = sigCounts[wi]
+		}
+
+		report.Stacks = append(report.Stacks, stat)
+	}
+
+	windowKey := report.Window
+	sort.Slice(report.Stacks, func(i, j int) bool {
+		ci, cj := report.Stacks[i].Counts[windowKey], report.Stacks[j].Counts[windowKey]
+		if ci != cj {
+			return ci > cj
+		}
+
+		if report.Stacks[i].Total != report.Stacks[j].Total {
+			return report.Stacks[i].Total > report.Stacks[j].Total
+		}
+
+		return report.Stacks[i].Signature < report.Stacks[j].Signature
+	})
+
+	if n > 0 && len(report.Stacks) > n {
+		report.Stacks = report.Stacks[:n]
+	}
+
+	return report
+}
+
+// ServeHTTP serves the top as json, "n" query parameter limits the number of stacks, "window" selects the window
+func (a *SlowlogAggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	n := 0
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		var err error
+		if n, err = strconv.Atoi(nStr); err != nil {
+			http.Error(w, "invalid n: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var window time.Duration
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		var err error
+		if window, err = time.ParseDuration(windowStr); err != nil {
+			http.Error(w, "invalid window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a.Top(n, window))
+}
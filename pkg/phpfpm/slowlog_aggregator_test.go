@@ -0,0 +1,141 @@
+package phpfpm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func slowlogEntry(pid int, ptr string, line int, funcs ...string) SlowlogEntry {
+	entry := SlowlogEntry{PoolName: "www", Pid: pid, ScriptFilename: "/app/index.php"}
+	for _, fun := range funcs {
+		entry.Stacktrace = append(entry.Stacktrace, SlowlogTraceEntry{PtrHex: ptr, FunName: fun, Path: "/app/src/" + fun + ".php", Line: line})
+	}
+
+	return entry
+}
+
+func TestSlowlogSignature(t *testing.T) {
+	assert.Equal(t,
+		SlowlogSignature(slowlogEntry(1, "0x1", 10, "sleep()", "handle()")),
+		SlowlogSignature(slowlogEntry(2, "0x2", 20, "sleep()", "handle()")),
+	)
+	assert.NotEqual(t,
+		SlowlogSignature(slowlogEntry(1, "0x1", 10, "sleep()", "handle()")),
+		SlowlogSignature(slowlogEntry(1, "0x1", 10, "curl_exec()", "handle()")),
+	)
+}
+
+func TestSlowlogAggregator_Top(t *testing.T) {
+	now := time.Unix(1736510400, 0)
+	a := NewSlowlogAggregator([]time.Duration{5 * time.Minute, time.Minute}, 0)
+	a.now = func() time.Time { return now }
+
+	for pid := 1; pid <= 5; pid++ {
+		a.Add(slowlogEntry(pid, "0x1", 10, "sleep()", "handle()"))
+	}
+
+	now = now.Add(2 * time.Minute)
+	a.Add(slowlogEntry(6, "0x1", 10, "curl_exec()", "handle()"))
+	a.Add(slowlogEntry(7, "0x1", 10, "curl_exec()", "handle()"))
+
+	report := a.Top(10, time.Minute)
+	assert.Equal(t, "1m", report.Window)
+	assert.Equal(t, []string{"1m", "5m"}, report.Windows)
+	require.Len(t, report.Stacks, 1)
+	assert.Equal(t, "curl_exec()", report.Stacks[0].Frames[0].Func)
+	assert.Equal(t, map[string]int{"1m": 2, "5m": 2}, report.Stacks[0].Counts)
+	assert.Equal(t, []int{6, 7}, report.Stacks[0].ExamplePids)
+
+	report = a.Top(10, 0)
+	assert.Equal(t, "5m", report.Window)
+	require.Len(t, report.Stacks, 2)
+	assert.Equal(t, "sleep()", report.Stacks[0].Frames[0].Func)
+	assert.Equal(t, map[string]int{"1m": 0, "5m": 5}, report.Stacks[0].Counts)
+	assert.Equal(t, []int{3, 4, 5}, report.Stacks[0].ExamplePids)
+	assert.Equal(t, []string{"www"}, report.Stacks[0].Pools)
+
+	assert.Len(t, a.Top(1, 0).Stacks, 1)
+
+	now = now.Add(10 * time.Minute)
+	assert.Empty(t, a.Top(10, 0).Stacks)
+}
+
+func TestSlowlogAggregator_EntryTime(t *testing.T) {
+	now := time.Unix(1736510400, 0)
+	a := NewSlowlogAggregator([]time.Duration{5 * time.Minute, time.Minute}, 0)
+	a.now = func() time.Time { return now }
+
+	for at, entry := range map[time.Duration]SlowlogEntry{
+		-3 * time.Minute:  slowlogEntry(1, "0x1", 10, "sleep()"),
+		-30 * time.Second: slowlogEntry(2, "0x1", 10, "curl_exec()"),
+		-time.Hour:        slowlogEntry(3, "0x1", 10, "sleep()"),
+	} {
+		entry.CreatedAt = now.Add(at)
+		a.Add(entry)
+	}
+
+	counts := make(map[string]map[string]int)
+	for _, stack := range a.Top(10, 0).Stacks {
+		counts[stack.Frames[0].Func] = stack.Counts
+	}
+	assert.Equal(t, map[string]map[string]int{
+		"sleep()":     {"1m": 0, "5m": 1},
+		"curl_exec()": {"1m": 1, "5m": 1},
+	}, counts)
+
+	// windows of replayed file end with its newest entry
+	replay := NewSlowlogAggregator([]time.Duration{time.Minute}, 0)
+	replay.Replay()
+	for pid := 1; pid <= 3; pid++ {
+		entry := slowlogEntry(pid, "0x1", 10, "sleep()")
+		entry.CreatedAt = now.Add(-24*time.Hour + time.Duration(pid)*time.Second)
+		replay.Add(entry)
+	}
+
+	report := replay.Top(10, time.Minute)
+	require.Len(t, report.Stacks, 1)
+	assert.Equal(t, map[string]int{"1m": 3}, report.Stacks[0].Counts)
+}
+
+func TestSlowlogAggregator_MaxStacks(t *testing.T) {
+	now := time.Unix(1736510400, 0)
+	a := NewSlowlogAggregator(nil, 1)
+	a.now = func() time.Time { return now }
+
+	a.Add(slowlogEntry(1, "0x1", 10, "sleep()"))
+	a.Add(slowlogEntry(2, "0x1", 10, "curl_exec()"))
+	assert.Equal(t, 1, a.Top(0, 0).Dropped)
+
+	// the first stack is forgotten after the longest window
+	now = now.Add(16 * time.Minute)
+	a.Add(slowlogEntry(3, "0x1", 10, "curl_exec()"))
+
+	report := a.Top(0, 0)
+	require.Len(t, report.Stacks, 1)
+	assert.Equal(t, "curl_exec()", report.Stacks[0].Frames[0].Func)
+	assert.Equal(t, 3, a.Added())
+}
+
+func TestSlowlogAggregator_ServeHTTP(t *testing.T) {
+	a := NewSlowlogAggregator(nil, 0)
+	a.Add(slowlogEntry(1, "0x1", 10, "sleep()"))
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slowlog/top?n=5&window=1m", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var report SlowlogReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, "1m", report.Window)
+	assert.Len(t, report.Stacks, 1)
+
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slowlog/top?window=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
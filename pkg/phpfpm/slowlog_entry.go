@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
 )
 
+// RedactSourceSlowlog is the source of slowlog entry redactions
+const RedactSourceSlowlog = "slowlog"
+
 type SlowlogTraceEntry struct {
 	PtrHex  string
 	FunName string
@@ -19,6 +24,8 @@ type SlowlogEntry struct {
 	Pid            int
 	ScriptFilename string
 	Stacktrace     []SlowlogTraceEntry
+	// Truncated is set when frames were dropped by trace depth limit or php-fpm marked the trace incomplete
+	Truncated bool
 }
 
 func (se *SlowlogEntry) Reset() {
@@ -27,6 +34,15 @@ func (se *SlowlogEntry) Reset() {
 	se.Pid = 0
 	se.ScriptFilename = ""
 	se.Stacktrace = se.Stacktrace[:0]
+	se.Truncated = false
+}
+
+// Redact replaces secrets in the script filename and trace paths
+func (se *SlowlogEntry) Redact(r *redact.Redactor) {
+	se.ScriptFilename = r.String(RedactSourceSlowlog, se.ScriptFilename)
+	for i := range se.Stacktrace {
+		se.Stacktrace[i].Path = r.String(RedactSourceSlowlog, se.Stacktrace[i].Path)
+	}
 }
 
 func (se *SlowlogEntry) String() string {
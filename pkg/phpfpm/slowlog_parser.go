@@ -8,6 +8,7 @@ import (
 	"io"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/code-tool/docker-fpm-wrapper/pkg/line"
@@ -19,19 +20,55 @@ const (
 	stateParseStacktrace
 )
 
-const stacktraceParsingTimeout = 25 * time.Millisecond
+const (
+	defaultSlowlogFlushTimeout = 25 * time.Millisecond
+	defaultSlowlogMaxLineSize  = 16 * 1024
+	slowlogLineBufferSize      = 64
+)
 
-var (
-	headerRegexp          = regexp.MustCompile(`^\[([^]]+)]\s+\[pool\s([^]]+)]\s+pid\s+(\d+)(?:[\r\n]|$)`)
-	stacktraceEntryRegexp = regexp.MustCompile(`^\[([^]]+)]\s+(\S+)\s+([^:]+):(\d+)(?:[\r\n]+|$)`)
+// Slowlog parse error reasons passed to SlowlogParserOptions.OnError
+const (
+	SlowlogErrHeader   = "header"
+	SlowlogErrFilename = "filename"
+	SlowlogErrFrame    = "frame"
 )
 
+var headerRegexp = regexp.MustCompile(`^\[([^]]+)]\s+\[pool\s([^]]+)]\s+pid\s+(\d+)$`)
+
+type SlowlogParserOptions struct {
+	// MaxTraceLen limits the number of kept frames, the entry is marked as truncated when frames are dropped.
+	// 0 keeps all frames.
+	MaxTraceLen int
+	// FlushTimeout is the delay after the last line when the entry without the trailing empty line is complete
+	FlushTimeout time.Duration
+	// MaxLineSize limits the line length, longer lines are skipped
+	MaxLineSize int
+	// OnError is called for every line which can't be parsed, reason is one of SlowlogErr* constants
+	OnError func(reason string, line []byte)
+}
+
+// SlowlogParser parses php-fpm slowlog stream. Entries end with an empty line, a header of the next entry
+// or FlushTimeout of silence, as php-fpm writes the empty line before the header of the next entry.
 type SlowlogParser struct {
-	maxTraceLen int
+	opts SlowlogParserOptions
 }
 
-func NewSlowlogParser(maxTraceLen int) *SlowlogParser {
-	return &SlowlogParser{maxTraceLen: maxTraceLen}
+func NewSlowlogParser(opts SlowlogParserOptions) *SlowlogParser {
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = defaultSlowlogFlushTimeout
+	}
+
+	if opts.MaxLineSize <= 0 {
+		opts.MaxLineSize = defaultSlowlogMaxLineSize
+	}
+
+	return &SlowlogParser{opts: opts}
+}
+
+func (slp *SlowlogParser) reportError(reason string, line []byte) {
+	if slp.opts.OnError != nil {
+		slp.opts.OnError(reason, line)
+	}
 }
 
 func (slp *SlowlogParser) parseHeader(line []byte, entry *SlowlogEntry) error {
@@ -41,7 +78,8 @@ func (slp *SlowlogParser) parseHeader(line []byte, entry *SlowlogEntry) error {
 		return errors.New("not a header")
 	}
 
-	entry.CreatedAt, err = time.Parse("02-Jan-2006 15:04:05", string(line[matches[2]:matches[3]]))
+	// php-fpm writes the local time
+	entry.CreatedAt, err = time.ParseInLocation("02-Jan-2006 15:04:05", string(line[matches[2]:matches[3]]), time.Local)
 	if err != nil {
 		return err
 	}
@@ -60,131 +98,225 @@ func (slp *SlowlogParser) parseFilename(line []byte, entry *SlowlogEntry) error
 	if !bytes.HasPrefix(line, prefix) {
 		return errors.New("not filename line")
 	}
-	entry.ScriptFilename = string(line[len(prefix) : len(line)-1])
+	entry.ScriptFilename = string(line[len(prefix):])
 
 	return nil
 }
 
-func (slp *SlowlogParser) parseStacktraceEntry(line []byte, entry *SlowlogEntry) error {
-	matches := stacktraceEntryRegexp.FindSubmatchIndex(line)
-	if len(matches) == 0 {
-		return errors.New("not a stacktrace entry")
+// parseSlowlogFrame parses "[0x00007f...] func() /path/file.php:12". Unknown functions (???), paths with colons
+// and frames without path or line are accepted.
+func parseSlowlogFrame(line string) (SlowlogTraceEntry, bool) {
+	if !strings.HasPrefix(line, "[") {
+		return SlowlogTraceEntry{}, false
 	}
 
-	lineN, err := strconv.Atoi(string(line[matches[8]:matches[9]]))
-	if err != nil {
-		return err
+	end := strings.IndexByte(line, ']')
+	if end == -1 {
+		return SlowlogTraceEntry{}, false
+	}
+
+	rest := strings.TrimSpace(line[end+1:])
+	if rest == "" {
+		return SlowlogTraceEntry{}, false
+	}
+
+	frame := SlowlogTraceEntry{PtrHex: line[1:end]}
+
+	var location string
+	frame.FunName, location, _ = strings.Cut(rest, " ")
+	frame.Path = strings.TrimSpace(location)
+
+	if pos := strings.LastIndexByte(frame.Path, ':'); pos != -1 {
+		if lineN, err := strconv.Atoi(frame.Path[pos+1:]); err == nil {
+			frame.Path, frame.Line = frame.Path[:pos], lineN
+		}
 	}
 
-	if slp.maxTraceLen > 0 && len(entry.Stacktrace) >= slp.maxTraceLen {
+	return frame, true
+}
+
+// isTruncationMarker reports whether the line marks incomplete trace, e.g. "[INCOMPLETE STACK]"
+func isTruncationMarker(line string) bool {
+	return strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.HasPrefix(line, "[0x")
+}
+
+func (slp *SlowlogParser) parseStacktraceEntry(line []byte, entry *SlowlogEntry) error {
+	frame, ok := parseSlowlogFrame(string(line))
+	if !ok {
+		if isTruncationMarker(string(line)) {
+			entry.Truncated = true
+			return nil
+		}
+
+		return errors.New("not a stacktrace entry")
+	}
+
+	if slp.opts.MaxTraceLen > 0 && len(entry.Stacktrace) >= slp.opts.MaxTraceLen {
+		entry.Truncated = true
 		return nil
 	}
 
-	entry.Stacktrace = append(entry.Stacktrace, SlowlogTraceEntry{
-		PtrHex:  string(line[matches[2]:matches[3]]),
-		FunName: string(line[matches[4]:matches[5]]),
-		Path:    string(line[matches[6]:matches[7]]),
-		Line:    lineN,
-	})
+	entry.Stacktrace = append(entry.Stacktrace, frame)
 
 	return nil
 }
 
-func (slp *SlowlogParser) parseLine(line []byte, entry *SlowlogEntry, state *int) bool {
+// parseLine returns true when the entry is complete, the line may be the header of the next entry then,
+// so it has to be parsed again with the new entry.
+func (slp *SlowlogParser) parseLine(line []byte, entry *SlowlogEntry, state *int) (complete bool, again bool) {
 	switch *state {
 	case stateParseHeader:
+		if len(line) == 0 {
+			break
+		}
+
 		if err := slp.parseHeader(line, entry); err != nil {
+			slp.reportError(SlowlogErrHeader, line)
 			entry.Reset()
 			break
 		}
 		*state = stateParseFilename
 	case stateParseFilename:
 		if err := slp.parseFilename(line, entry); err != nil {
+			slp.reportError(SlowlogErrFilename, line)
 			entry.Reset()
 			*state = stateParseHeader
-			break
+
+			return false, true
 		}
 		*state = stateParseStacktrace
 	case stateParseStacktrace:
-		if bytes.Equal(line, []byte{'\n'}) {
+		if len(line) == 0 {
 			*state = stateParseHeader
-			return true
+			return true, false
 		}
 
-		if err := slp.parseStacktraceEntry(line, entry); err != nil {
-			entry.Reset()
+		if headerRegexp.Match(line) {
 			*state = stateParseHeader
-			break
+			return true, true
+		}
+
+		if err := slp.parseStacktraceEntry(line, entry); err != nil {
+			slp.reportError(SlowlogErrFrame, line)
 		}
 	default:
 		panic("unexpected state")
 	}
 
-	return false
+	return false, false
 }
 
 func (slp *SlowlogParser) createEntry() SlowlogEntry {
-	return SlowlogEntry{Stacktrace: make([]SlowlogTraceEntry, 0, slp.maxTraceLen)}
+	return SlowlogEntry{Stacktrace: make([]SlowlogTraceEntry, 0, slp.opts.MaxTraceLen)}
 }
 
-func (slp *SlowlogParser) Parse(ctx context.Context, r io.Reader, out chan SlowlogEntry) error {
-	errCh := make(chan error)
-	lineCh := make(chan []byte)
-
-	go func() {
-		bufioReader := bufio.NewReader(r)
+// readLines sends lines without line endings to lineCh until the reader fails or ctx is done
+func (slp *SlowlogParser) readLines(ctx context.Context, r io.Reader, lineCh chan<- []byte, errCh chan<- error) {
+	bufioReader := bufio.NewReaderSize(r, slp.opts.MaxLineSize)
 
-		for {
-			buf, err := line.ReadOne(bufioReader, false)
-			if err != nil {
-				errCh <- err
-
-				return
-			}
+	for {
+		buf, err := line.ReadOne(bufioReader, true)
+		if len(buf) > 0 || err == nil {
+			lineCopy := bytes.TrimRight(bytes.Clone(buf), "\r\n")
 
 			select {
 			case <-ctx.Done():
 				return
-			default:
-				lineCopy := make([]byte, len(buf))
-				copy(lineCopy, buf)
-
-				lineCh <- lineCopy
+			case lineCh <- lineCopy:
 			}
 		}
-	}()
 
-	timeoutTimer := time.NewTimer(25 * time.Millisecond)
+		if err != nil {
+			errCh <- err
+			return
+		}
+	}
+}
+
+// Parse sends parsed entries to out until the reader fails or ctx is done.
+// The pending entry is sent before the reader error is returned.
+func (slp *SlowlogParser) Parse(ctx context.Context, r io.Reader, out chan SlowlogEntry) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lineCh := make(chan []byte, slowlogLineBufferSize)
+	errCh := make(chan error, 1)
+	go slp.readLines(ctx, r, lineCh, errCh)
+
+	timeoutTimer := time.NewTimer(slp.opts.FlushTimeout)
 	timeoutTimer.Stop()
 
 	entry := slp.createEntry()
 	state := stateParseHeader
 
+	emit := func() bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case out <- entry:
+		}
+
+		entry = slp.createEntry()
+
+		return true
+	}
+
+	// flush completes the pending entry, an entry without filename is dropped
+	flush := func() bool {
+		defer func() { state = stateParseHeader }()
+
+		switch state {
+		case stateParseStacktrace:
+			return emit()
+		case stateParseFilename:
+			slp.reportError(SlowlogErrFilename, nil)
+			entry.Reset()
+		}
+
+		return true
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case err := <-errCh:
 			timeoutTimer.Stop()
+
+			// lines read before the error are still buffered
+			for len(lineCh) > 0 {
+				slp.parseBuffered(<-lineCh, &entry, &state, emit)
+			}
+			flush()
+
 			return err
 		case <-timeoutTimer.C:
-			if state == stateParseStacktrace {
-				out <- entry
+			if !flush() {
+				return nil
 			}
-
-			entry = slp.createEntry()
-			state = stateParseHeader
 		case lineBuf := <-lineCh:
-			if slp.parseLine(lineBuf, &entry, &state) {
-				timeoutTimer.Stop()
-				out <- entry
-				entry = slp.createEntry()
-				continue
+			if !slp.parseBuffered(lineBuf, &entry, &state, emit) {
+				return nil
 			}
 
-			if state == stateParseStacktrace {
-				timeoutTimer.Reset(stacktraceParsingTimeout)
+			timeoutTimer.Stop()
+			if state != stateParseHeader {
+				timeoutTimer.Reset(slp.opts.FlushTimeout)
 			}
 		}
 	}
 }
+
+// parseBuffered parses the line and emits the complete entry, it returns false when ctx is done
+func (slp *SlowlogParser) parseBuffered(line []byte, entry *SlowlogEntry, state *int, emit func() bool) bool {
+	complete, again := slp.parseLine(line, entry, state)
+	if complete && !emit() {
+		return false
+	}
+
+	if again {
+		slp.parseLine(line, entry, state)
+	}
+
+	return true
+}
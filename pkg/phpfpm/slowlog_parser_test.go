@@ -6,10 +6,13 @@ import (
 	"errors"
 	"io"
 	"os"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSlowlogParser(t *testing.T) {
@@ -21,7 +24,7 @@ func TestSlowlogParser(t *testing.T) {
 	}
 	defer f.Close()
 
-	slp := NewSlowlogParser(0)
+	slp := NewSlowlogParser(SlowlogParserOptions{})
 	out := make(chan SlowlogEntry)
 
 	pipeReader, pipeWriter := io.Pipe()
@@ -73,3 +76,117 @@ func TestSlowlogParser(t *testing.T) {
 
 	assert.True(t, bytes.Contains(allContent, []byte(entries[0].String())))
 }
+
+// parseSlowlogString parses the whole input and returns entries and reported error reasons
+func parseSlowlogString(t *testing.T, opts SlowlogParserOptions, in string) ([]SlowlogEntry, []string) {
+	var reasons []string
+	opts.OnError = func(reason string, _ []byte) {
+		reasons = append(reasons, reason)
+	}
+
+	out := make(chan SlowlogEntry, 16)
+	err := NewSlowlogParser(opts).Parse(context.Background(), strings.NewReader(in), out)
+	require.ErrorIs(t, err, io.EOF)
+	close(out)
+
+	var entries []SlowlogEntry
+	for e := range out {
+		entries = append(entries, e)
+	}
+
+	return entries, reasons
+}
+
+func TestSlowlogParserCRLF(t *testing.T) {
+	in := "\r\n[19-Oct-2026 10:00:00]  [pool www] pid 42\r\n" +
+		"script_filename = /app/public/index.php\r\n" +
+		"[0x00007f0000000010] sleep() /app/src/C:/Foo.php:12\r\n" +
+		"[0x00007f0000000020] ??? \r\n" +
+		"[0x00007f0000000030] {main}() /app/public/index.php:3\r\n"
+
+	entries, reasons := parseSlowlogString(t, SlowlogParserOptions{}, in)
+	require.Len(t, entries, 1)
+	assert.Empty(t, reasons)
+
+	entry := entries[0]
+	assert.Equal(t, "www", entry.PoolName)
+	assert.Equal(t, 42, entry.Pid)
+	assert.Equal(t, "/app/public/index.php", entry.ScriptFilename)
+	assert.False(t, entry.Truncated)
+	assert.Equal(t, []SlowlogTraceEntry{
+		{PtrHex: "0x00007f0000000010", FunName: "sleep()", Path: "/app/src/C:/Foo.php", Line: 12},
+		{PtrHex: "0x00007f0000000020", FunName: "???"},
+		{PtrHex: "0x00007f0000000030", FunName: "{main}()", Path: "/app/public/index.php", Line: 3},
+	}, entry.Stacktrace)
+}
+
+func TestSlowlogParserTruncated(t *testing.T) {
+	in := "[19-Oct-2026 10:00:00]  [pool www] pid 42\n" +
+		"script_filename = /app/index.php\n" +
+		"[0x00007f0000000010] a() /app/a.php:1\n" +
+		"[0x00007f0000000020] b() /app/b.php:2\n" +
+		"[0x00007f0000000030] c() /app/c.php:3\n" +
+		"\n" +
+		"[19-Oct-2026 10:00:01]  [pool www] pid 43\n" +
+		"script_filename = /app/index.php\n" +
+		"[0x00007f0000000010] a() /app/a.php:1\n" +
+		"[INCOMPLETE STACK]\n"
+
+	entries, reasons := parseSlowlogString(t, SlowlogParserOptions{MaxTraceLen: 2}, in)
+	require.Len(t, entries, 2)
+	assert.Empty(t, reasons)
+
+	assert.True(t, entries[0].Truncated)
+	assert.Len(t, entries[0].Stacktrace, 2)
+
+	assert.True(t, entries[1].Truncated)
+	assert.Equal(t, 43, entries[1].Pid)
+	assert.Len(t, entries[1].Stacktrace, 1)
+}
+
+func TestSlowlogParserErrors(t *testing.T) {
+	in := "garbage\n" +
+		"[19-Oct-2026 10:00:00]  [pool www] pid 42\n" +
+		"[19-Oct-2026 10:00:01]  [pool www] pid 43\n" +
+		"script_filename = /app/index.php\n" +
+		"[0x00007f0000000010] a() /app/a.php:1\n" +
+		"broken frame\n" +
+		"[19-Oct-2026 10:00:02]  [pool www] pid 44\n" +
+		"script_filename = /app/index.php\n" +
+		"[0x00007f0000000010] b() /app/b.php:2\n"
+
+	entries, reasons := parseSlowlogString(t, SlowlogParserOptions{}, in)
+	require.Len(t, entries, 2)
+	assert.Equal(t, []string{SlowlogErrHeader, SlowlogErrFilename, SlowlogErrFrame}, reasons)
+
+	assert.Equal(t, 43, entries[0].Pid)
+	assert.Len(t, entries[0].Stacktrace, 1)
+	assert.Equal(t, 44, entries[1].Pid)
+	assert.Equal(t, "b()", entries[1].Stacktrace[0].FunName)
+}
+
+func TestSlowlogParserFlushTimeout(t *testing.T) {
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeWriter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := make(chan SlowlogEntry)
+	go func() {
+		_ = NewSlowlogParser(SlowlogParserOptions{FlushTimeout: 10 * time.Millisecond}).Parse(ctx, pipeReader, out)
+	}()
+
+	_, err := io.WriteString(pipeWriter, "[19-Oct-2026 10:00:00]  [pool www] pid 42\n"+
+		"script_filename = /app/index.php\n"+
+		"[0x00007f0000000010] a() /app/a.php:1\n")
+	require.NoError(t, err)
+
+	select {
+	case entry := <-out:
+		assert.Equal(t, 42, entry.Pid)
+		assert.Len(t, entry.Stacktrace, 1)
+	case <-time.After(time.Second):
+		t.Fatal("entry wasn't flushed")
+	}
+}
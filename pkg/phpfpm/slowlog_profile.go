@@ -0,0 +1,192 @@
+package phpfpm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultSlowlogProfileSamples = 10000
+
+// SlowlogSample is one slowlog stack, Frames start with the innermost call as in slowlog
+type SlowlogSample struct {
+	Time   time.Time
+	Pool   string
+	Frames []SlowlogTraceEntry
+}
+
+// SlowlogProfileFilter selects samples of the pool, in [From, To) time range. Zero values match everything.
+type SlowlogProfileFilter struct {
+	Pool string
+	From time.Time
+	To   time.Time
+}
+
+func (f SlowlogProfileFilter) match(sample *SlowlogSample) bool {
+	if f.Pool != "" && sample.Pool != f.Pool {
+		return false
+	}
+
+	if !f.From.IsZero() && sample.Time.Before(f.From) {
+		return false
+	}
+
+	return f.To.IsZero() || sample.Time.Before(f.To)
+}
+
+// SlowlogProfile keeps the last slowlog stacks to build flame graphs of slow requests.
+// It serves them in folded format on /stacks and as pprof profile on /pprof.
+type SlowlogProfile struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	samples []SlowlogSample
+	next    int
+	count   int
+
+	mux *http.ServeMux
+}
+
+// NewSlowlogProfile creates profile which keeps up to size last samples
+func NewSlowlogProfile(prefix string, size int) *SlowlogProfile {
+	if size < 1 {
+		size = defaultSlowlogProfileSamples
+	}
+
+	p := &SlowlogProfile{now: time.Now, samples: make([]SlowlogSample, size), mux: http.NewServeMux()}
+	p.mux.HandleFunc("GET "+prefix+"/stacks", p.serveFolded)
+	p.mux.HandleFunc("GET "+prefix+"/pprof", p.servePprof)
+
+	return p
+}
+
+func (p *SlowlogProfile) Add(entry SlowlogEntry) {
+	if len(entry.Stacktrace) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.samples[p.next] = SlowlogSample{Time: p.now(), Pool: entry.PoolName, Frames: entry.Stacktrace}
+	p.next = (p.next + 1) % len(p.samples)
+	p.count = min(p.count+1, len(p.samples))
+}
+
+// Samples returns kept samples matched by filter, the oldest first
+func (p *SlowlogProfile) Samples(filter SlowlogProfileFilter) []SlowlogSample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]SlowlogSample, 0, p.count)
+	for i := 0; i < p.count; i++ {
+		sample := &p.samples[(p.next-p.count+i+len(p.samples))%len(p.samples)]
+		if filter.match(sample) {
+			result = append(result, *sample)
+		}
+	}
+
+	return result
+}
+
+func foldedFrame(frame SlowlogTraceEntry) string {
+	// ';' separates frames and the last space separates the count
+	return strings.NewReplacer(";", ":", "\n", " ").Replace(frame.FunName + " " + frame.Path)
+}
+
+// WriteFolded writes samples in Brendan Gregg's collapsed stack format, frames start with the outermost call
+func WriteFolded(w io.Writer, samples []SlowlogSample) error {
+	counts := make(map[string]int)
+
+	var sb strings.Builder
+	for i := range samples {
+		sb.Reset()
+
+		frames := samples[i].Frames
+		for j := len(frames) - 1; j >= 0; j-- {
+			sb.WriteString(foldedFrame(frames[j]))
+			if j > 0 {
+				sb.WriteByte(';')
+			}
+		}
+
+		counts[sb.String()]++
+	}
+
+	stacks := make([]string, 0, len(counts))
+	for stack := range counts {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	bw := bufio.NewWriter(w)
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(bw, "%s %d\n", stack, counts[stack]); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func (p *SlowlogProfile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// parseFilter reads pool, from and to (RFC3339) or since (duration) query parameters
+func (p *SlowlogProfile) parseFilter(r *http.Request) (SlowlogProfileFilter, error) {
+	query := r.URL.Query()
+	filter := SlowlogProfileFilter{Pool: query.Get("pool")}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		var d time.Duration
+		if d, err = time.ParseDuration(since); err != nil {
+			return filter, fmt.Errorf("invalid since: %w", err)
+		}
+
+		filter.From = p.now().Add(-d)
+	}
+
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+func (p *SlowlogProfile) serveFolded(w http.ResponseWriter, r *http.Request) {
+	filter, err := p.parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = WriteFolded(w, p.Samples(filter))
+}
+
+func (p *SlowlogProfile) servePprof(w http.ResponseWriter, r *http.Request) {
+	filter, err := p.parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="slowlog.pb.gz"`)
+	_ = WritePprof(w, p.Samples(filter), p.now())
+}
@@ -0,0 +1,115 @@
+package phpfpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func newTestSlowlogProfile() *SlowlogProfile {
+	now := time.Unix(1736510400, 0)
+	p := NewSlowlogProfile("/slowlog", 3)
+	p.now = func() time.Time { return now }
+
+	p.Add(slowlogEntry(1, "0x1", 10, "sleep()", "handle()"))
+	now = now.Add(time.Minute)
+	p.Add(slowlogEntry(2, "0x1", 10, "sleep()", "handle()"))
+
+	admin := slowlogEntry(3, "0x1", 10, "curl_exec()", "handle()")
+	admin.PoolName = "admin"
+	p.Add(admin)
+	p.Add(SlowlogEntry{PoolName: "www", Pid: 4})
+
+	return p
+}
+
+func TestSlowlogProfile_Samples(t *testing.T) {
+	p := newTestSlowlogProfile()
+
+	assert.Len(t, p.Samples(SlowlogProfileFilter{}), 3)
+	assert.Len(t, p.Samples(SlowlogProfileFilter{Pool: "www"}), 2)
+	assert.Len(t, p.Samples(SlowlogProfileFilter{From: time.Unix(1736510400+30, 0)}), 2)
+	assert.Len(t, p.Samples(SlowlogProfileFilter{To: time.Unix(1736510400+30, 0)}), 1)
+
+	// the oldest sample is replaced
+	p.Add(slowlogEntry(5, "0x1", 10, "usleep()"))
+	samples := p.Samples(SlowlogProfileFilter{})
+	require.Len(t, samples, 3)
+	assert.Equal(t, "usleep()", samples[2].Frames[0].FunName)
+}
+
+func TestWriteFolded(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteFolded(&buf, newTestSlowlogProfile().Samples(SlowlogProfileFilter{})))
+
+	assert.Equal(t,
+		"handle() /app/src/handle().php;curl_exec() /app/src/curl_exec().php 1\n"+
+			"handle() /app/src/handle().php;sleep() /app/src/sleep().php 2\n",
+		buf.String(),
+	)
+}
+
+func TestWritePprof(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WritePprof(&buf, newTestSlowlogProfile().Samples(SlowlogProfileFilter{}), time.Unix(1736510500, 0)))
+
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	counts := make(map[protowire.Number]int)
+	var strs []string
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		require.GreaterOrEqual(t, n, 0)
+		data = data[n:]
+
+		if num == pprofProfileStringTable {
+			s, n := protowire.ConsumeString(data)
+			strs = append(strs, s)
+			data = data[n:]
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			data = data[n:]
+		}
+
+		require.GreaterOrEqual(t, n, 0)
+		counts[num]++
+	}
+
+	assert.Equal(t, 3, counts[pprofProfileSample])
+	assert.Equal(t, 3, counts[pprofProfileFunction])
+	assert.Equal(t, 3, counts[pprofProfileLocation])
+	assert.Equal(t, "", strs[0])
+	assert.Contains(t, strs, "slow_requests")
+	assert.Contains(t, strs, "curl_exec()")
+	assert.Contains(t, strs, "admin")
+}
+
+func TestSlowlogProfile_ServeHTTP(t *testing.T) {
+	p := newTestSlowlogProfile()
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slowlog/stacks?pool=admin&since=5m", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), "\n"))
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slowlog/pprof", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slowlog/stacks?from=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
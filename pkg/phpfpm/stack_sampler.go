@@ -0,0 +1,234 @@
+package phpfpm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	processStateRunning = "Running"
+	processStateIdle    = "Idle"
+	maxStatusSampleRate = 10
+	phpspyGracePeriod   = 5 * time.Second
+)
+
+// StackSampler collects stacks of busy workers of the pool for the duration
+type StackSampler interface {
+	Sample(ctx context.Context, client *StatusClient, duration time.Duration) ([]SlowlogSample, error)
+}
+
+// busyWorkers returns running workers of the status except the one serving the status request itself
+func busyWorkers(status *Status, statusPath string) []ProcessStatus {
+	var result []ProcessStatus
+	for _, proc := range status.Processes {
+		if proc.State != processStateRunning || proc.RequestPath() == statusPath {
+			continue
+		}
+
+		result = append(result, proc)
+	}
+
+	return result
+}
+
+// StatusSampler polls the pool full status page and records the script and request of every busy worker.
+// It doesn't need ptrace, but shows requests instead of php functions.
+type StatusSampler struct {
+	interval time.Duration
+}
+
+// NewStatusSampler creates sampler polling rate times per second, at most 10 as every poll occupies a worker
+func NewStatusSampler(rate int) *StatusSampler {
+	rate = max(1, min(rate, maxStatusSampleRate))
+
+	return &StatusSampler{interval: time.Second / time.Duration(rate)}
+}
+
+func (s *StatusSampler) Sample(ctx context.Context, client *StatusClient, duration time.Duration) ([]SlowlogSample, error) {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var result []SlowlogSample
+	var lastErr error
+	polled := false
+	for {
+		status, err := client.Status(ctx)
+		switch {
+		case err == nil:
+			polled = true
+			result = append(result, s.samples(client.Pool(), status)...)
+		case ctx.Err() == nil:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if !polled && lastErr != nil {
+				return nil, lastErr
+			}
+
+			return result, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *StatusSampler) samples(pool Pool, status *Status) []SlowlogSample {
+	now := time.Now()
+
+	var result []SlowlogSample
+	for _, proc := range busyWorkers(status, pool.StatusPath) {
+		result = append(result, SlowlogSample{
+			Time: now,
+			Pool: pool.Name,
+			Frames: []SlowlogTraceEntry{{
+				FunName: strings.TrimSpace(proc.RequestMethod + " " + proc.RequestPath()),
+				Path:    proc.Script,
+			}},
+		})
+	}
+
+	return result
+}
+
+// PhpspySampler runs phpspy (https://github.com/adsr/phpspy) for every busy worker, it needs ptrace capability
+type PhpspySampler struct {
+	path string
+	rate int
+}
+
+func NewPhpspySampler(path string, rate int) *PhpspySampler {
+	return &PhpspySampler{path: path, rate: max(1, rate)}
+}
+
+func (s *PhpspySampler) Sample(ctx context.Context, client *StatusClient, duration time.Duration) ([]SlowlogSample, error) {
+	status, err := client.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := busyWorkers(status, client.Pool().StatusPath)
+
+	ctx, cancel := context.WithTimeout(ctx, duration+phpspyGracePeriod)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result []SlowlogSample
+		errs   []error
+	)
+	for _, proc := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			samples, err := s.sampleWorker(ctx, client.Pool().Name, proc.Pid, duration)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			result = append(result, samples...)
+		}()
+	}
+	wg.Wait()
+
+	// workers may exit during sampling, it's an error only when nothing was sampled
+	if len(errs) > 0 && len(errs) == len(workers) {
+		return nil, errors.Join(errs...)
+	}
+
+	return result, nil
+}
+
+func (s *PhpspySampler) sampleWorker(ctx context.Context, poolName string, pid int, duration time.Duration) ([]SlowlogSample, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, s.path,
+		"--pid", strconv.Itoa(pid),
+		"--rate-hz", strconv.Itoa(s.rate),
+		"--time-limit-ms", strconv.FormatInt(duration.Milliseconds(), 10),
+	)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	samples, parseErr := ParsePhpspyOutput(stdout, poolName, time.Now())
+	if err = cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("phpspy pid %d: %w: %s", pid, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return samples, parseErr
+}
+
+// ParsePhpspyOutput parses phpspy traces: frame lines "<depth> <func> <file>:<line>" separated by empty lines.
+// Comment lines starting with # are skipped.
+func ParsePhpspyOutput(r io.Reader, poolName string, t time.Time) ([]SlowlogSample, error) {
+	var result []SlowlogSample
+	var frames []SlowlogTraceEntry
+
+	flush := func() {
+		if len(frames) > 0 {
+			result = append(result, SlowlogSample{Time: t, Pool: poolName, Frames: frames})
+			frames = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		depthStr, rest, ok := strings.Cut(line, " ")
+		depth, err := strconv.Atoi(depthStr)
+		if !ok || err != nil {
+			continue
+		}
+
+		if depth == 0 {
+			flush()
+		}
+
+		funName, location, _ := strings.Cut(rest, " ")
+		path, lineStr := location, ""
+		if pos := strings.LastIndexByte(location, ':'); pos != -1 {
+			path, lineStr = location[:pos], location[pos+1:]
+		}
+
+		lineN, _ := strconv.Atoi(lineStr)
+		frames = append(frames, SlowlogTraceEntry{FunName: funName, Path: path, Line: lineN})
+	}
+	flush()
+
+	return result, scanner.Err()
+}
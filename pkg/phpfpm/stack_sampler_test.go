@@ -0,0 +1,118 @@
+package phpfpm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/fcgi"
+)
+
+func TestParsePhpspyOutput(t *testing.T) {
+	output := "0 sleep <internal>:-1\n" +
+		"1 App\\Controller::index /app/src/Controller.php:12\n" +
+		"2 <main> /app/public/index.php:5\n" +
+		"# mem 2097152 2097152\n" +
+		"\n" +
+		"0 usleep <internal>:-1\n" +
+		"0 curl_exec <internal>:-1\n" +
+		"1 <main> /app/public/index.php:7\n" +
+		"garbage\n"
+
+	samples, err := ParsePhpspyOutput(strings.NewReader(output), "www", time.Unix(1, 0))
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+
+	assert.Equal(t, "www", samples[0].Pool)
+	assert.Equal(t, []SlowlogTraceEntry{
+		{FunName: "sleep", Path: "<internal>", Line: -1},
+		{FunName: "App\\Controller::index", Path: "/app/src/Controller.php", Line: 12},
+		{FunName: "<main>", Path: "/app/public/index.php", Line: 5},
+	}, samples[0].Frames)
+	assert.Len(t, samples[1].Frames, 1)
+	assert.Len(t, samples[2].Frames, 2)
+}
+
+func TestStatusSampler_Samples(t *testing.T) {
+	status := &Status{Processes: []ProcessStatus{
+		{Pid: 1, State: "Running", RequestMethod: "GET", RequestURI: "/api/users?page=2", Script: "/app/index.php"},
+		{Pid: 2, State: "Idle", RequestMethod: "GET", RequestURI: "/", Script: "/app/index.php"},
+		{Pid: 3, State: "Running", RequestMethod: "GET", RequestURI: "/status?json&full", Script: "-"},
+	}}
+
+	samples := NewStatusSampler(99).samples(Pool{Name: "www", StatusPath: "/status"}, status)
+	require.Len(t, samples, 1)
+	assert.Equal(t, []SlowlogTraceEntry{{FunName: "GET /api/users", Path: "/app/index.php"}}, samples[0].Frames)
+}
+
+type fakeStackSampler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *fakeStackSampler) Sample(_ context.Context, client *StatusClient, _ time.Duration) ([]SlowlogSample, error) {
+	if s.started != nil {
+		close(s.started)
+		<-s.release
+	}
+
+	return []SlowlogSample{{Pool: client.Pool().Name, Frames: []SlowlogTraceEntry{{FunName: "sleep", Path: "<internal>"}}}}, nil
+}
+
+func TestProfileHandler(t *testing.T) {
+	clients := []*StatusClient{NewStatusClient(Pool{Name: "www", Listen: "127.0.0.1:9000"}, fcgi.Options{})}
+	sampler := &fakeStackSampler{}
+	h := NewProfileHandler(clients, sampler, time.Minute)
+
+	tests := []struct {
+		name string
+		url  string
+		code int
+		body string
+	}{
+		{name: "folded", url: "/fpm/www/profile?seconds=1", code: http.StatusOK, body: "sleep <internal> 1\n"},
+		{name: "unknown pool", url: "/fpm/admin/profile", code: http.StatusNotFound},
+		{name: "too long", url: "/fpm/www/profile?seconds=120", code: http.StatusBadRequest},
+		{name: "unknown format", url: "/fpm/www/profile?format=svg", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProfileHandler_Concurrent(t *testing.T) {
+	clients := []*StatusClient{NewStatusClient(Pool{Name: "www", Listen: "127.0.0.1:9000"}, fcgi.Options{})}
+	sampler := &fakeStackSampler{started: make(chan struct{}), release: make(chan struct{})}
+	h := NewProfileHandler(clients, sampler, time.Minute)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fpm/www/profile", nil))
+	}()
+
+	<-sampler.started
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fpm/www/profile", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	close(sampler.release)
+	wg.Wait()
+}
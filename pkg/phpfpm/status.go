@@ -1,11 +1,16 @@
 package phpfpm
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"time"
+	"net/http"
+	"strings"
 
-	"github.com/tomasen/fcgi_client"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/fcgi"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
 )
 
 type Status struct {
@@ -23,26 +28,121 @@ type Status struct {
 	MaxActiveProcesses int    `json:"max active processes"`
 	MaxChildrenReached int    `json:"max children reached"`
 	SlowRequests       int    `json:"slow requests"`
+
+	Processes []ProcessStatus `json:"processes"`
 }
 
-func GetStats(net, addr, statusPath string) (*Status, error) {
-	fcgi, err := fcgiclient.DialTimeout(net, addr, time.Second)
-	if err != nil {
-		return nil, err
+type ProcessStatus struct {
+	Pid               int     `json:"pid"`
+	State             string  `json:"state"`
+	StartTime         int     `json:"start time"`
+	StartSince        int     `json:"start since"`
+	Requests          int     `json:"requests"`
+	RequestDuration   int     `json:"request duration"`
+	RequestMethod     string  `json:"request method"`
+	RequestURI        string  `json:"request uri"`
+	ContentLength     int     `json:"content length"`
+	User              string  `json:"user"`
+	Script            string  `json:"script"`
+	LastRequestCPU    float64 `json:"last request cpu"`
+	LastRequestMemory int     `json:"last request memory"`
+}
+
+// Idle is true when the worker waits for a request
+func (ps *ProcessStatus) Idle() bool {
+	return ps.State == processStateIdle
+}
+
+// RequestPath returns request uri without query string
+func (ps *ProcessStatus) RequestPath() string {
+	path, _, _ := strings.Cut(ps.RequestURI, "?")
+
+	return path
+}
+
+func (ps *ProcessStatus) QueryString() string {
+	_, query, _ := strings.Cut(ps.RequestURI, "?")
+
+	return query
+}
+
+// Redact replaces secrets in request uri, script and user of processes
+func (s *Status) Redact(r *redact.Redactor) {
+	for i := range s.Processes {
+		proc := &s.Processes[i]
+		proc.RequestURI = r.String(RedactSourceStatus, proc.RequestURI)
+		proc.Script = r.String(RedactSourceStatus, proc.Script)
+		proc.User = r.String(RedactSourceStatus, proc.User)
 	}
-	defer fcgi.Close()
+}
+
+func (s *Status) FindProcess(pid int) (ProcessStatus, bool) {
+	for i := range s.Processes {
+		if s.Processes[i].Pid == pid {
+			return s.Processes[i], true
+		}
+	}
+
+	return ProcessStatus{}, false
+}
+
+var (
+	ErrStatusNotFound     = errors.New("status page not found")
+	ErrStatusAccessDenied = errors.New("status page access denied")
+)
+
+// StatusPageError is returned when status page responds with non 200 code
+type StatusPageError struct {
+	StatusCode int
+	Body       string
+}
 
-	env := map[string]string{
-		"QUERY_STRING":    "json&full",
-		"SCRIPT_FILENAME": statusPath,
-		"SCRIPT_NAME":     statusPath,
+func (e *StatusPageError) Error() string {
+	return fmt.Sprintf("status page responded with code %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *StatusPageError) Is(target error) bool {
+	switch target {
+	case ErrStatusNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrStatusAccessDenied:
+		return e.StatusCode == http.StatusForbidden || e.StatusCode == http.StatusUnauthorized
+	default:
+		return false
 	}
-	resp, err := fcgi.Get(env)
+}
+
+const maxErrorBodyLen = 256
+
+func newStatusPageError(resp *fcgi.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLen))
+
+	return &StatusPageError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+}
+
+// StatusRequest creates FastCGI request to the status or ping page
+func StatusRequest(path, query string) *fcgi.Request {
+	return &fcgi.Request{Params: map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"REQUEST_METHOD":    http.MethodGet,
+		"QUERY_STRING":      query,
+		"REQUEST_URI":       path,
+		"SCRIPT_FILENAME":   path,
+		"SCRIPT_NAME":       path,
+	}}
+}
+
+func GetStats(ctx context.Context, client *fcgi.Client, statusPath string) (*Status, error) {
+	resp, err := client.Do(ctx, StatusRequest(statusPath, "json&full"))
 	if err != nil {
 		return nil, err
 	}
 	defer tryClose(resp.Body)
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusPageError(resp)
+	}
+
 	s := Status{}
 	err = json.NewDecoder(resp.Body).Decode(&s)
 	if err != nil {
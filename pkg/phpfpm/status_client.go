@@ -0,0 +1,72 @@
+package phpfpm
+
+import (
+	"context"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/fcgi"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+// Sources of status redactions
+const (
+	RedactSourceStatus     = "status"
+	RedactSourceStatusPage = "status_page"
+)
+
+// StatusClient requests status pages of the pool
+type StatusClient struct {
+	pool     Pool
+	client   *fcgi.Client
+	redactor *redact.Redactor
+}
+
+func NewStatusClient(pool Pool, opts fcgi.Options) *StatusClient {
+	net, addr := pool.StatusAddr()
+
+	return &StatusClient{pool: pool, client: fcgi.NewClient(net, addr, opts)}
+}
+
+// NewStatusClients creates clients for the pools with configured status path
+func NewStatusClients(pools []Pool, opts fcgi.Options) []*StatusClient {
+	var result []*StatusClient
+
+	for _, pool := range pools {
+		if pool.StatusPath == "" {
+			continue
+		}
+
+		result = append(result, NewStatusClient(pool, opts))
+	}
+
+	return result
+}
+
+// SetRedactor sets redactor of request uri, script and user of Status processes and of status pages
+// proxied by StatusHandler, it has to be called before the client is used
+func (c *StatusClient) SetRedactor(r *redact.Redactor) {
+	c.redactor = r
+}
+
+func (c *StatusClient) Pool() Pool {
+	return c.pool
+}
+
+// Page requests status or ping page, the caller must close response body
+func (c *StatusClient) Page(ctx context.Context, path, query string) (*fcgi.Response, error) {
+	return c.client.Do(ctx, StatusRequest(path, query))
+}
+
+func (c *StatusClient) Status(ctx context.Context) (*Status, error) {
+	status, err := GetStats(ctx, c.client, c.pool.StatusPath)
+	if err != nil || c.redactor == nil {
+		return status, err
+	}
+
+	status.Redact(c.redactor)
+
+	return status, nil
+}
+
+func (c *StatusClient) Close() error {
+	return c.client.Close()
+}
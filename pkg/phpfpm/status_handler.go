@@ -0,0 +1,100 @@
+package phpfpm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+var ErrUnknownPool = errors.New("unknown pool")
+
+type StatusHandler struct {
+	mux     *http.ServeMux
+	clients map[string]*StatusClient
+}
+
+// NewStatusHandler proxies /fpm/{pool}/status and /fpm/{pool}/ping to the pool status and ping pages.
+// Query string is passed as is, so status page formats like ?full&json work. Status pages of clients with redactor
+// are redacted as text whatever the format is.
+func NewStatusHandler(clients []*StatusClient) *StatusHandler {
+	h := &StatusHandler{mux: http.NewServeMux(), clients: make(map[string]*StatusClient, len(clients))}
+	for _, client := range clients {
+		h.clients[client.Pool().Name] = client
+	}
+
+	h.mux.HandleFunc("GET /fpm/{pool}/status", h.serveStatus)
+	h.mux.HandleFunc("GET /fpm/{pool}/ping", h.servePing)
+
+	return h
+}
+
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *StatusHandler) getClient(w http.ResponseWriter, r *http.Request) *StatusClient {
+	client, ok := h.clients[r.PathValue("pool")]
+	if !ok {
+		http.Error(w, ErrUnknownPool.Error(), http.StatusNotFound)
+	}
+
+	return client
+}
+
+func (h *StatusHandler) proxy(w http.ResponseWriter, r *http.Request, client *StatusClient, path string, redacted bool) {
+	resp, err := client.Page(r.Context(), path, r.URL.RawQuery)
+	if err != nil {
+		code := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+		}
+
+		http.Error(w, err.Error(), code)
+		return
+	}
+	defer tryClose(resp.Body)
+
+	var body []byte
+	if redacted && client.redactor != nil {
+		if body, err = io.ReadAll(resp.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		body = client.redactor.Bytes(RedactSourceStatusPage, body)
+		resp.Header.Del("Content-Length")
+	}
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if body != nil {
+		_, _ = w.Write(body)
+		return
+	}
+
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (h *StatusHandler) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if client := h.getClient(w, r); client != nil {
+		h.proxy(w, r, client, client.Pool().StatusPath, true)
+	}
+}
+
+func (h *StatusHandler) servePing(w http.ResponseWriter, r *http.Request) {
+	client := h.getClient(w, r)
+	if client == nil {
+		return
+	}
+
+	if client.Pool().PingPath == "" {
+		http.Error(w, "ping.path is not configured", http.StatusNotFound)
+		return
+	}
+
+	h.proxy(w, r, client, client.Pool().PingPath, false)
+}
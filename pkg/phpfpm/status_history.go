@@ -0,0 +1,93 @@
+package phpfpm
+
+import (
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// StatusSample is a result of one successful status page poll
+type StatusSample struct {
+	Time   time.Time
+	Status *Status
+	// Families are set when the status page was requested in openmetrics format
+	Families map[string]*dto.MetricFamily
+}
+
+// StatusHistory is a ring buffer of the last samples of one pool, it isn't safe for concurrent use
+type StatusHistory struct {
+	samples []StatusSample
+	next    int
+	count   int
+}
+
+func NewStatusHistory(size int) *StatusHistory {
+	if size < 1 {
+		size = 1
+	}
+
+	return &StatusHistory{samples: make([]StatusSample, size)}
+}
+
+func (h *StatusHistory) Add(sample StatusSample) {
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	h.count = min(h.count+1, len(h.samples))
+}
+
+func (h *StatusHistory) Len() int {
+	return h.count
+}
+
+// At returns i-th sample, 0 is the oldest one
+func (h *StatusHistory) At(i int) StatusSample {
+	return h.samples[(h.next-h.count+i+len(h.samples))%len(h.samples)]
+}
+
+func (h *StatusHistory) Last() (StatusSample, bool) {
+	if h.count == 0 {
+		return StatusSample{}, false
+	}
+
+	return h.At(h.count - 1), true
+}
+
+// RequestRate returns accepted connections per second over the kept samples.
+// Samples taken before php-fpm restart, when accepted conn counter was reset, are ignored.
+func (h *StatusHistory) RequestRate() (float64, bool) {
+	last, ok := h.Last()
+	if !ok {
+		return 0, false
+	}
+
+	first := last
+	for i := h.count - 2; i >= 0; i-- {
+		sample := h.At(i)
+		if sample.Status.AcceptedConn > first.Status.AcceptedConn {
+			break
+		}
+
+		first = sample
+	}
+
+	elapsed := last.Time.Sub(first.Time).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	return float64(last.Status.AcceptedConn-first.Status.AcceptedConn) / elapsed, true
+}
+
+// PeakActiveProcesses returns the maximum number of active processes over the kept samples
+func (h *StatusHistory) PeakActiveProcesses() (int, bool) {
+	if h.count == 0 {
+		return 0, false
+	}
+
+	peak := 0
+	for i := 0; i < h.count; i++ {
+		peak = max(peak, h.At(i).Status.ActiveProcesses)
+	}
+
+	return peak, true
+}
@@ -0,0 +1,47 @@
+package phpfpm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusHistory(t *testing.T) {
+	h := NewStatusHistory(3)
+
+	_, ok := h.RequestRate()
+	assert.False(t, ok)
+
+	now := time.Now()
+	add := func(sec, accepted, active int) {
+		h.Add(StatusSample{
+			Time:   now.Add(time.Duration(sec) * time.Second),
+			Status: &Status{AcceptedConn: accepted, ActiveProcesses: active},
+		})
+	}
+
+	add(0, 100, 7)
+	add(5, 110, 2)
+	add(10, 130, 3)
+	add(15, 150, 1)
+
+	assert.Equal(t, 3, h.Len())
+	assert.Equal(t, 110, h.At(0).Status.AcceptedConn)
+
+	rate, ok := h.RequestRate()
+	assert.True(t, ok)
+	assert.InDelta(t, 4.0, rate, 0.001)
+
+	peak, ok := h.PeakActiveProcesses()
+	assert.True(t, ok)
+	assert.Equal(t, 3, peak)
+
+	// php-fpm restart resets the counter
+	add(20, 10, 1)
+	add(25, 30, 1)
+
+	rate, ok = h.RequestRate()
+	assert.True(t, ok)
+	assert.InDelta(t, 4.0, rate, 0.001)
+}
@@ -0,0 +1,277 @@
+package phpfpm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+type polledPool struct {
+	client *StatusClient
+
+	mu                  sync.Mutex
+	history             *StatusHistory
+	openMetricsFallback bool
+
+	polled       bool
+	lastErr      error
+	lastDuration time.Duration
+	// processes is the last full status with processes
+	processes *Status
+}
+
+// PoolSnapshot is the state of one pool built from the last poll and the kept samples
+type PoolSnapshot struct {
+	Pool string
+	// Up is false when the last poll failed, Last sample is stale then
+	Up             bool
+	ScrapeDuration time.Duration
+
+	Last      StatusSample
+	HasSample bool
+
+	RequestRate         float64
+	HasRequestRate      bool
+	PeakActiveProcesses int
+}
+
+// StatusPoller requests status pages of all pools in background and keeps the last samples,
+// so metrics scrapes don't touch php-fpm.
+type StatusPoller struct {
+	log      *zap.Logger
+	metrics  *PromMetrics
+	mode     MetricsMode
+	interval time.Duration
+	pools    []*polledPool
+	// trackProcesses makes openmetrics polls request the full status too
+	trackProcesses bool
+}
+
+func NewStatusPoller(
+	log *zap.Logger,
+	metrics *PromMetrics,
+	clients []*StatusClient,
+	mode MetricsMode,
+	interval time.Duration,
+	historySize int,
+) *StatusPoller {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	p := &StatusPoller{log: log, metrics: metrics, mode: mode, interval: interval}
+
+	for _, client := range clients {
+		p.pools = append(p.pools, &polledPool{client: client, history: NewStatusHistory(historySize)})
+	}
+
+	return p
+}
+
+// TrackProcesses makes the poller keep processes of pools in openmetrics mode too, which costs one more status
+// page request per poll. It has to be called before Run.
+func (p *StatusPoller) TrackProcesses() {
+	p.trackProcesses = true
+}
+
+func (p *StatusPoller) Mode() MetricsMode {
+	return p.mode
+}
+
+func (p *StatusPoller) useOpenMetrics(pp *polledPool) bool {
+	switch p.mode {
+	case MetricsModeOpenMetrics:
+		return true
+	case MetricsModeAuto:
+		pp.mu.Lock()
+		defer pp.mu.Unlock()
+
+		return !pp.openMetricsFallback
+	default:
+		return false
+	}
+}
+
+func (p *StatusPoller) fetch(ctx context.Context, pp *polledPool) (StatusSample, error) {
+	poolName := pp.client.Pool().Name
+
+	if p.useOpenMetrics(pp) {
+		families, err := pp.client.OpenMetrics(ctx)
+		if err == nil {
+			return StatusSample{Time: time.Now(), Status: statusFromOpenMetrics(poolName, families), Families: families}, nil
+		}
+
+		if p.mode != MetricsModeAuto || !errors.Is(err, ErrOpenMetricsUnsupported) {
+			return StatusSample{}, err
+		}
+
+		pp.mu.Lock()
+		pp.openMetricsFallback = true
+		pp.mu.Unlock()
+
+		p.log.Info("status page doesn't support openmetrics, fallback to json", zap.String("pool", poolName))
+	}
+
+	status, err := pp.client.Status(ctx)
+	if err != nil {
+		return StatusSample{}, err
+	}
+
+	return StatusSample{Time: time.Now(), Status: status}, nil
+}
+
+func (p *StatusPoller) fetchProcesses(ctx context.Context, pp *polledPool) *Status {
+	status, err := pp.client.Status(ctx)
+	if err != nil {
+		p.log.Warn("can't poll full status page", zap.String("pool", pp.client.Pool().Name), zap.Error(err))
+		return nil
+	}
+
+	return status
+}
+
+func (p *StatusPoller) pollPool(ctx context.Context, pp *polledPool) {
+	startedAt := time.Now()
+	sample, err := p.fetch(ctx, pp)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	processes := sample.Status
+	if err == nil && sample.Families != nil {
+		processes = nil
+		if p.trackProcesses {
+			processes = p.fetchProcesses(ctx, pp)
+		}
+	}
+
+	pp.mu.Lock()
+	pp.polled = true
+	pp.lastErr = err
+	pp.lastDuration = time.Since(startedAt)
+	if err == nil {
+		pp.history.Add(sample)
+		pp.processes = processes
+	}
+	pp.mu.Unlock()
+
+	if err != nil {
+		reason := scrapeErrorReason(err)
+		p.metrics.ScrapeErrors.WithLabelValues(pp.client.Pool().Name, reason).Inc()
+		p.log.Error("can't poll status page",
+			zap.String("pool", pp.client.Pool().Name), zap.String("reason", reason), zap.Error(err),
+		)
+	}
+}
+
+// Poll requests status pages of all pools once
+func (p *StatusPoller) Poll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pp := range p.pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			p.pollPool(ctx, pp)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Run polls every pool with the interval until ctx is done, a hung pool doesn't delay the others
+func (p *StatusPoller) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pp := range p.pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(p.interval)
+			defer ticker.Stop()
+
+			for {
+				p.pollPool(ctx, pp)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Snapshots returns the state of pools which were polled at least once
+func (p *StatusPoller) Snapshots() []PoolSnapshot {
+	result := make([]PoolSnapshot, 0, len(p.pools))
+
+	for _, pp := range p.pools {
+		pp.mu.Lock()
+		if !pp.polled {
+			pp.mu.Unlock()
+			continue
+		}
+
+		snapshot := PoolSnapshot{
+			Pool:           pp.client.Pool().Name,
+			Up:             pp.lastErr == nil,
+			ScrapeDuration: pp.lastDuration,
+		}
+		snapshot.Last, snapshot.HasSample = pp.history.Last()
+		snapshot.RequestRate, snapshot.HasRequestRate = pp.history.RequestRate()
+		snapshot.PeakActiveProcesses, _ = pp.history.PeakActiveProcesses()
+		pp.mu.Unlock()
+
+		result = append(result, snapshot)
+	}
+
+	return result
+}
+
+// FindPoolProcess looks up a worker of the pool by pid in the last polled status, it never requests php-fpm
+func (p *StatusPoller) FindPoolProcess(poolName string, pid int) (ProcessStatus, bool) {
+	for _, pp := range p.pools {
+		if pp.client.Pool().Name != poolName {
+			continue
+		}
+
+		pp.mu.Lock()
+		defer pp.mu.Unlock()
+
+		if pp.processes == nil {
+			return ProcessStatus{}, false
+		}
+
+		return pp.processes.FindProcess(pid)
+	}
+
+	return ProcessStatus{}, false
+}
+
+// FindProcess looks up a worker by pid in the last polled status of all pools and returns its pool name
+func (p *StatusPoller) FindProcess(pid int) (string, ProcessStatus, bool) {
+	for _, pp := range p.pools {
+		pp.mu.Lock()
+		processes := pp.processes
+		pp.mu.Unlock()
+
+		if processes == nil {
+			continue
+		}
+
+		if proc, ok := processes.FindProcess(pid); ok {
+			return pp.client.Pool().Name, proc, true
+		}
+	}
+
+	return "", ProcessStatus{}, false
+}
@@ -0,0 +1,38 @@
+package phpfpm
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/fcgi"
+)
+
+func TestStatusPoller_FindProcess(t *testing.T) {
+	dir := t.TempDir()
+	clients := []*StatusClient{
+		NewStatusClient(Pool{Name: "www", Listen: filepath.Join(dir, "www.sock"), StatusPath: "/status"}, fcgi.Options{}),
+		NewStatusClient(Pool{Name: "api", Listen: filepath.Join(dir, "api.sock"), StatusPath: "/status"}, fcgi.Options{}),
+	}
+	poller := NewStatusPoller(zap.NewNop(), NewPromMetrics(), clients, MetricsModeJSON, time.Second, 2)
+
+	_, _, ok := poller.FindProcess(42)
+	assert.False(t, ok)
+
+	poller.pools[1].processes = &Status{Name: "api", Processes: []ProcessStatus{{Pid: 42, RequestURI: "/orders"}}}
+
+	poolName, proc, ok := poller.FindProcess(42)
+	assert.True(t, ok)
+	assert.Equal(t, "api", poolName)
+	assert.Equal(t, "/orders", proc.RequestURI)
+
+	_, ok = poller.FindPoolProcess("www", 42)
+	assert.False(t, ok)
+
+	proc, ok = poller.FindPoolProcess("api", 42)
+	assert.True(t, ok)
+	assert.Equal(t, 42, proc.Pid)
+}
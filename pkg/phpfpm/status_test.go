@@ -0,0 +1,64 @@
+package phpfpm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+func TestStatus_FindProcess(t *testing.T) {
+	data, err := os.ReadFile("testdata/status_full.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	status := Status{}
+	if !assert.NoError(t, json.Unmarshal(data, &status)) {
+		return
+	}
+
+	assert.Equal(t, "www", status.Name)
+	assert.Len(t, status.Processes, 2)
+
+	proc, ok := status.FindProcess(4219)
+	assert.True(t, ok)
+	assert.Equal(t, "POST", proc.RequestMethod)
+	assert.Equal(t, "/index.php", proc.RequestPath())
+	assert.Equal(t, "module=storage&action=putAll", proc.QueryString())
+	assert.Equal(t, 1250261, proc.RequestDuration)
+
+	_, ok = status.FindProcess(1)
+	assert.False(t, ok)
+}
+
+func TestStatus_Redact(t *testing.T) {
+	redactor, err := redact.New(redact.Options{Detectors: []string{redact.DetectorParams, redact.DetectorEmail}})
+	require.NoError(t, err)
+
+	status := Status{Processes: []ProcessStatus{
+		{RequestURI: "/reset?email=bob@example.com&token=abc", User: "bob@example.com", Script: "/var/www/index.php"},
+	}}
+	status.Redact(redactor)
+
+	proc := status.Processes[0]
+	assert.Equal(t, "/reset", proc.RequestPath())
+	assert.Equal(t, "email=[REDACTED]&token=[REDACTED]", proc.QueryString())
+	assert.Equal(t, "[REDACTED]", proc.User)
+	assert.Equal(t, "/var/www/index.php", proc.Script)
+}
+
+func TestStatusPageError_Is(t *testing.T) {
+	var err error = &StatusPageError{StatusCode: 404, Body: "File not found."}
+	assert.ErrorIs(t, err, ErrStatusNotFound)
+	assert.NotErrorIs(t, err, ErrStatusAccessDenied)
+
+	err = fmt.Errorf("pool www: %w", &StatusPageError{StatusCode: 403, Body: "Access denied."})
+	assert.ErrorIs(t, err, ErrStatusAccessDenied)
+	assert.NotErrorIs(t, err, ErrStatusNotFound)
+}
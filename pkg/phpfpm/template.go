@@ -0,0 +1,163 @@
+package phpfpm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	envGlobalPrefix = "FPM_GLOBAL_"
+	envPoolPrefix   = "FPM_POOL_"
+
+	defaultPoolName = "www"
+)
+
+// env names can't contain dots, so directives are matched by their normalized names
+var globalDirectives = []string{
+	"pid", "error_log", "syslog.facility", "syslog.ident", "log_level", "log_limit", "log_buffering",
+	"emergency_restart_threshold", "emergency_restart_interval", "process_control_timeout", "process.max",
+	"process.priority", "rlimit_files", "rlimit_core", "events.mechanism", "systemd_interval",
+}
+
+var poolDirectives = []string{
+	"user", "group", "listen", "listen.backlog", "listen.owner", "listen.group", "listen.mode", "listen.acl_users",
+	"listen.acl_groups", "listen.allowed_clients", "listen.setfib", "process.priority", "process.dumpable",
+	"pm", "pm.max_children", "pm.start_servers", "pm.min_spare_servers", "pm.max_spare_servers",
+	"pm.max_spawn_rate", "pm.process_idle_timeout", "pm.max_requests", "pm.status_listen", "pm.status_path",
+	"ping.path", "ping.response", "access.log", "access.format", "slowlog", "request_slowlog_timeout",
+	"request_slowlog_trace_depth", "request_terminate_timeout", "request_terminate_timeout_track_finished",
+	"rlimit_files", "rlimit_core", "chroot", "chdir", "catch_workers_output", "decorate_workers_output",
+	"clear_env", "security.limit_extensions", "apparmor_hat",
+}
+
+// array directives take the rest of env name as the array key, e.g. PHP_ADMIN_VALUE_MEMORY_LIMIT
+var poolArrayDirectives = []string{"php_admin_value", "php_admin_flag", "php_value", "php_flag", "env"}
+
+func normalizeDirective(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// matchDirective converts env name part into php-fpm directive
+func matchDirective(envName string, directives, arrayDirectives []string) (string, bool) {
+	for _, directive := range directives {
+		if normalizeDirective(directive) == envName {
+			return directive, true
+		}
+	}
+
+	for _, directive := range arrayDirectives {
+		prefix := normalizeDirective(directive) + "_"
+		if strings.HasPrefix(envName, prefix) && len(envName) > len(prefix) {
+			key := envName[len(prefix):]
+			if directive != "env" {
+				key = strings.ToLower(key)
+			}
+
+			return directive + "[" + key + "]", true
+		}
+	}
+
+	return "", false
+}
+
+// splitPoolEnv splits WWW_PM_MAX_CHILDREN into pool name and directive, pool names may contain underscores
+func splitPoolEnv(name string) (string, string, bool) {
+	parts := strings.Split(name, "_")
+
+	for i := 1; i < len(parts); i++ {
+		directive, ok := matchDirective(strings.Join(parts[i:], "_"), poolDirectives, poolArrayDirectives)
+		if ok {
+			return strings.ToLower(strings.Join(parts[:i], "_")), directive, true
+		}
+	}
+
+	return "", "", false
+}
+
+// ApplyEnv sets values from FPM_GLOBAL_<DIRECTIVE> and FPM_POOL_<POOL>_<DIRECTIVE> env variables
+// and returns names of variables which don't match any known directive.
+func (o *ConfigOverride) ApplyEnv(env []string) []string {
+	var unknown []string
+
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(name, envGlobalPrefix):
+			directive, ok := matchDirective(strings.TrimPrefix(name, envGlobalPrefix), globalDirectives, nil)
+			if !ok {
+				unknown = append(unknown, name)
+				continue
+			}
+
+			o.SetGlobal(directive, value)
+		case strings.HasPrefix(name, envPoolPrefix):
+			pool, directive, ok := splitPoolEnv(strings.TrimPrefix(name, envPoolPrefix))
+			if !ok {
+				unknown = append(unknown, name)
+				continue
+			}
+
+			o.SetPool(pool, directive, value)
+		}
+	}
+
+	return unknown
+}
+
+// WireProxies points error_log and slowlog of the pools to fifo files in dir, which are proxied by the wrapper,
+// and enables status page of the pools which don't have it. Values set by env are kept.
+func (o *ConfigOverride) WireProxies(dir string, pools []Pool) {
+	if _, ok := o.global["error_log"]; !ok {
+		o.SetGlobal("error_log", filepath.Join(dir, "error.log"))
+	}
+
+	for _, pool := range pools {
+		if _, ok := o.pools[pool.Name]["slowlog"]; !ok {
+			o.SetPool(pool.Name, "slowlog", filepath.Join(dir, "$pool.slow.log"))
+		}
+
+		if pool.StatusPath == "" {
+			o.SetPool(pool.Name, "pm.status_path", "/status")
+		}
+	}
+}
+
+// SetStandaloneDefaults sets values required by php-fpm when there is no base config.
+// The default pool www listens on 127.0.0.1:9000 as in official php images, other pools listen on sockets in dir.
+func (o *ConfigOverride) SetStandaloneDefaults(dir string) {
+	if len(o.pools) == 0 {
+		o.pools[defaultPoolName] = make(map[string]string)
+	}
+
+	for pool, values := range o.pools {
+		defaults := map[string]string{
+			"listen":               filepath.Join(dir, pool+".sock"),
+			"pm":                   "dynamic",
+			"pm.max_children":      "5",
+			"pm.start_servers":     "2",
+			"pm.min_spare_servers": "1",
+			"pm.max_spare_servers": "3",
+		}
+
+		if pool == defaultPoolName {
+			defaults["listen"] = "127.0.0.1:9000"
+		}
+
+		// php-fpm refuses to run workers as root without explicit user
+		if os.Geteuid() == 0 {
+			defaults["user"] = "www-data"
+			defaults["group"] = "www-data"
+		}
+
+		for key, value := range defaults {
+			if _, ok := values[key]; !ok {
+				values[key] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package phpfpm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigOverride_ApplyEnv(t *testing.T) {
+	o := NewConfigOverride("")
+	unknown := o.ApplyEnv([]string{
+		"FPM_GLOBAL_LOG_LEVEL=notice",
+		"FPM_POOL_WWW_PM_MAX_CHILDREN=20",
+		"FPM_POOL_WWW_REQUEST_SLOWLOG_TIMEOUT=5s",
+		"FPM_POOL_BACK_OFFICE_LISTEN=/run/back.sock",
+		"FPM_POOL_WWW_PHP_ADMIN_VALUE_MEMORY_LIMIT=256M",
+		"FPM_POOL_WWW_ENV_APP_ENV=prod",
+		"FPM_POOL_WWW_UNKNOWN=1",
+		"FPM_CONFIG=/etc/php-fpm.conf",
+	})
+
+	assert.Equal(t, []string{"FPM_POOL_WWW_UNKNOWN"}, unknown)
+	assert.Equal(t, map[string]string{"log_level": "notice"}, o.global)
+	assert.Equal(t, map[string]string{
+		"pm.max_children":               "20",
+		"request_slowlog_timeout":       "5s",
+		"php_admin_value[memory_limit]": "256M",
+		"env[APP_ENV]":                  "prod",
+	}, o.pools["www"])
+	assert.Equal(t, map[string]string{"listen": "/run/back.sock"}, o.pools["back_office"])
+}
+
+func TestParseConfigOverride(t *testing.T) {
+	o := NewConfigOverride("")
+	o.ApplyEnv([]string{"FPM_POOL_WWW_PM_MAX_CHILDREN=20"})
+	o.SetStandaloneDefaults("/tmp/fpm")
+
+	c, err := ParseConfigOverride(o)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	o.WireProxies("/tmp/fpm", c.Pools)
+	c, err = ParseConfigOverride(o)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "/tmp/fpm/error.log", c.ErrorLog)
+	assert.Len(t, c.Pools, 1)
+	assert.Equal(t, "www", c.Pools[0].Name)
+	assert.Equal(t, "127.0.0.1:9000", c.Pools[0].Listen)
+	assert.Equal(t, 20, c.Pools[0].MaxChildren)
+	assert.Equal(t, "/status", c.Pools[0].StatusPath)
+	assert.Equal(t, "/tmp/fpm/www.slow.log", c.Pools[0].SlowlogPath)
+}
@@ -0,0 +1,246 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const DefaultReplacement = "[REDACTED]"
+
+// Built-in detectors
+const (
+	// DetectorJWT matches json web tokens
+	DetectorJWT = "jwt"
+	// DetectorAPIKey matches keys with well known prefixes: stripe, github, gitlab, slack, aws access key id, google
+	DetectorAPIKey = "api-key"
+	DetectorEmail  = "email"
+	// DetectorCard matches card numbers of 13-19 digits with optional space or dash separators passing the Luhn check
+	DetectorCard = "card"
+	// DetectorParams matches values of query params with names from Options.Params
+	DetectorParams = "params"
+)
+
+// DefaultParams are names of query params redacted by DetectorParams when Options.Params is empty
+var DefaultParams = []string{
+	"token", "access_token", "refresh_token", "id_token", "auth", "api_key", "apikey", "key",
+	"secret", "client_secret", "password", "passwd", "signature", "sig",
+}
+
+var (
+	emailRegexp  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	jwtRegexp    = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	apiKeyRegexp = regexp.MustCompile(`\b(?:` +
+		`(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{16,}|` +
+		`gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|` +
+		`glpat-[A-Za-z0-9_\-]{20,}|` +
+		`xox[abposr]-[A-Za-z0-9\-]{10,}|` +
+		`AKIA[0-9A-Z]{16}|` +
+		`AIza[0-9A-Za-z_\-]{35}` +
+		`)`)
+	cardRegexp = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+)
+
+// Options configure the redactor
+type Options struct {
+	// Detectors are names of built-in detectors
+	Detectors []string
+	// Params are names of query params redacted by DetectorParams, DefaultParams when empty
+	Params []string
+	// Rules are custom rules, rule name to regexp, the whole match is replaced
+	Rules map[string]string
+	// Replacement of redacted values, DefaultReplacement when empty
+	Replacement string
+}
+
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+	// keep returns length of the match prefix kept as is
+	keep func(match []byte) int
+	// valid checks the match, invalid matches aren't replaced
+	valid func(match []byte) bool
+}
+
+// Redactor replaces secrets found by rules and counts replacements by source and rule.
+// Nil Redactor returns values as is.
+type Redactor struct {
+	rules       []rule
+	replacement []byte
+	redactions  *prometheus.CounterVec
+}
+
+// paramsRegexp matches name=value of the params in a query string, url or any text containing it
+func paramsRegexp(params []string) (*regexp.Regexp, error) {
+	quoted := make([]string, 0, len(params))
+	for _, param := range params {
+		quoted = append(quoted, regexp.QuoteMeta(param))
+	}
+
+	return regexp.Compile(`(?i)(?:^|[?&;\s"'])(?:` + strings.Join(quoted, "|") + `)=[^&#;\s"'<]+`)
+}
+
+// keepParamName keeps the param name with the delimiter before it and '='
+func keepParamName(match []byte) int {
+	return bytes.IndexByte(match, '=') + 1
+}
+
+func luhnValid(match []byte) bool {
+	sum, n := 0, 0
+	for i := len(match) - 1; i >= 0; i-- {
+		c := match[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+
+	return n > 0 && sum%10 == 0
+}
+
+// New creates redactor of built-in detectors and custom rules
+func New(opts Options) (*Redactor, error) {
+	if opts.Replacement == "" {
+		opts.Replacement = DefaultReplacement
+	}
+
+	r := &Redactor{
+		replacement: []byte(opts.Replacement),
+		redactions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "phpfpm",
+				Name:      "redactions_total",
+				Help:      "The number of redacted values by source and rule",
+			},
+			[]string{"source", "rule"},
+		),
+	}
+
+	for _, detector := range opts.Detectors {
+		switch detector {
+		case DetectorJWT:
+			r.rules = append(r.rules, rule{name: detector, pattern: jwtRegexp})
+		case DetectorAPIKey:
+			r.rules = append(r.rules, rule{name: detector, pattern: apiKeyRegexp})
+		case DetectorEmail:
+			r.rules = append(r.rules, rule{name: detector, pattern: emailRegexp})
+		case DetectorCard:
+			r.rules = append(r.rules, rule{name: detector, pattern: cardRegexp, valid: luhnValid})
+		case DetectorParams:
+			params := opts.Params
+			if len(params) == 0 {
+				params = DefaultParams
+			}
+
+			re, err := paramsRegexp(params)
+			if err != nil {
+				return nil, err
+			}
+
+			r.rules = append(r.rules, rule{name: detector, pattern: re, keep: keepParamName})
+		default:
+			return nil, fmt.Errorf("unknown redact detector: %s", detector)
+		}
+	}
+
+	names := make([]string, 0, len(opts.Rules))
+	for name := range opts.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		re, err := regexp.Compile(opts.Rules[name])
+		if err != nil {
+			return nil, fmt.Errorf("redact rule %s: %w", name, err)
+		}
+
+		r.rules = append(r.rules, rule{name: name, pattern: re})
+	}
+
+	return r, nil
+}
+
+// LoadRules reads json object of custom rules, s is either the json itself or the path of json file
+func LoadRules(s string) (map[string]string, error) {
+	data := []byte(s)
+	if !strings.HasPrefix(strings.TrimSpace(s), "{") {
+		var err error
+		if data, err = os.ReadFile(s); err != nil {
+			return nil, err
+		}
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("can't decode redact rules: %w", err)
+	}
+
+	return result, nil
+}
+
+// Bytes returns b with secrets replaced, source is the label of counted replacements.
+// b is returned as is when nothing is found.
+func (r *Redactor) Bytes(source string, b []byte) []byte {
+	if r == nil {
+		return b
+	}
+
+	for i := range r.rules {
+		rl := &r.rules[i]
+		if !rl.pattern.Match(b) {
+			continue
+		}
+
+		n := 0
+		b = rl.pattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			if rl.valid != nil && !rl.valid(match) {
+				return match
+			}
+
+			keep := 0
+			if rl.keep != nil {
+				keep = rl.keep(match)
+			}
+
+			n++
+			return append(append(make([]byte, 0, keep+len(r.replacement)), match[:keep]...), r.replacement...)
+		})
+		if n > 0 {
+			r.redactions.WithLabelValues(source, rl.name).Add(float64(n))
+		}
+	}
+
+	return b
+}
+
+// String returns s with secrets replaced, source is the label of counted replacements
+func (r *Redactor) String(source, s string) string {
+	if r == nil || len(r.rules) == 0 || s == "" {
+		return s
+	}
+
+	return string(r.Bytes(source, []byte(s)))
+}
+
+func (r *Redactor) Describe(ch chan<- *prometheus.Desc) {
+	r.redactions.Describe(ch)
+}
+
+func (r *Redactor) Collect(ch chan<- prometheus.Metric) {
+	r.redactions.Collect(ch)
+}
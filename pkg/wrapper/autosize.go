@@ -0,0 +1,161 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/procfs"
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/cgroup"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+const (
+	autosizeOff   = "off"
+	autosizeLog   = "log"
+	autosizeApply = "apply"
+)
+
+type autosizer struct {
+	log      *zap.Logger
+	mode     string
+	pools    []phpfpm.Pool
+	opts     phpfpm.AutosizeOptions
+	warmup   time.Duration
+	override *phpfpm.ConfigOverride
+	path     string
+}
+
+func newAutosizer(log *zap.Logger, cfg *Options, pools []phpfpm.Pool, override *phpfpm.ConfigOverride) (*autosizer, error) {
+	switch cfg.FpmAutosize {
+	case autosizeLog, autosizeApply:
+	default:
+		return nil, fmt.Errorf("unknown autosize mode: %s", cfg.FpmAutosize)
+	}
+
+	workerMemory, err := phpfpm.ParseByteSize(cfg.FpmAutosizeWorkerMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	reservedMemory, err := phpfpm.ParseByteSize(cfg.FpmAutosizeReservedMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	cg, err := cgroup.Detect()
+	if err != nil {
+		return nil, err
+	}
+
+	memoryLimit, err := cg.MemoryLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &autosizer{
+		log:   log,
+		mode:  cfg.FpmAutosize,
+		pools: pools,
+		opts: phpfpm.AutosizeOptions{
+			MemoryLimit:    memoryLimit,
+			ReservedMemory: reservedMemory,
+			WorkerMemory:   workerMemory,
+		},
+		warmup:   cfg.FpmAutosizeWarmup,
+		override: override,
+		path:     cfg.FpmOverrideConfig,
+	}, nil
+}
+
+// recommend logs recommended pm.max_children and writes them into the override config in apply mode
+func (a *autosizer) recommend() (bool, error) {
+	recommended, err := phpfpm.Autosize(a.pools, a.opts)
+	if err != nil {
+		return false, err
+	}
+
+	for _, pool := range a.pools {
+		a.log.Info("pm.max_children recommendation",
+			zap.String("pool", pool.Name),
+			zap.Int("configured", pool.MaxChildren),
+			zap.Int("recommended", recommended[pool.Name]),
+			zap.Uint64("memory_limit", a.opts.MemoryLimit),
+			zap.Uint64("worker_memory", a.opts.WorkerMemory),
+		)
+	}
+
+	if a.mode != autosizeApply {
+		return false, nil
+	}
+
+	for pool, maxChildren := range recommended {
+		a.override.SetPool(pool, "pm.max_children", strconv.Itoa(maxChildren))
+	}
+
+	return true, a.override.WriteFile(a.path)
+}
+
+// prepare applies the configured worker memory estimate before php-fpm starts.
+// It returns fpm config path: the override config in apply mode, so the later reload picks up measured values.
+func (a *autosizer) prepare(fpmConfigPath string) (string, error) {
+	if a.opts.WorkerMemory != 0 {
+		if _, err := a.recommend(); err != nil {
+			return fpmConfigPath, err
+		}
+	}
+
+	if a.mode != autosizeApply {
+		return fpmConfigPath, nil
+	}
+
+	if err := a.override.WriteFile(a.path); err != nil {
+		return fpmConfigPath, err
+	}
+
+	return a.path, nil
+}
+
+// measure waits for workers to warm up, measures their memory and reloads php-fpm when the config was changed
+func (a *autosizer) measure(ctx context.Context, fpmProcess *phpfpm.Process) {
+	if a.opts.WorkerMemory != 0 {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(a.warmup):
+	}
+
+	fs, err := procfs.NewDefaultFS()
+	if err != nil {
+		a.log.Error("can't measure worker memory", zap.Error(err))
+		return
+	}
+
+	workerMemory, workers, err := phpfpm.MeasureWorkerMemory(fs, fpmProcess.Pid())
+	if err != nil {
+		a.log.Error("can't measure worker memory", zap.Error(err))
+		return
+	}
+
+	a.log.Info("worker memory measured", zap.Uint64("worker_memory", workerMemory), zap.Int("workers", workers))
+	a.opts.WorkerMemory = workerMemory
+
+	applied, err := a.recommend()
+	if err != nil {
+		a.log.Error("can't autosize pools", zap.Error(err))
+		return
+	}
+
+	if applied {
+		if err = fpmProcess.Signal(syscall.SIGUSR2); err != nil {
+			a.log.Error("can't reload php-fpm", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package wrapper
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/cgroup"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+func fpmProcessLister(log *zap.Logger, masterPid func() int) (cgroup.ProcessLister, error) {
+	fs, err := procfs.NewDefaultFS()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() map[int]string {
+		nodes, err := phpfpm.FindProcessTree(fs, masterPid())
+		if err != nil {
+			log.Error("can't read fpm process tree", zap.Error(err))
+			return nil
+		}
+
+		result := make(map[int]string, len(nodes))
+		for _, node := range nodes {
+			result[node.Proc.PID] = node.Pool
+		}
+
+		return result
+	}, nil
+}
+
+// fpmExitChecker treats workers which were idle or serving their pm.max_requests request in the last poll
+// of statusPoller as recycled, OOM killer picks workers growing while serving a request
+func fpmExitChecker(statusPoller *phpfpm.StatusPoller, pools []phpfpm.Pool) cgroup.ExitChecker {
+	maxRequests := make(map[string]int, len(pools))
+	for _, pool := range pools {
+		maxRequests[pool.Name] = pool.MaxRequests
+	}
+
+	return func(pid int, pool string) bool {
+		proc, ok := statusPoller.FindPoolProcess(pool, pid)
+		if !ok {
+			return false
+		}
+
+		if proc.Idle() {
+			return true
+		}
+
+		return maxRequests[pool] > 0 && proc.Requests >= maxRequests[pool]
+	}
+}
+
+// startCgroupMonitoring registers cgroup collector and starts OOM watcher, it has to be called before statusPoller runs
+func startCgroupMonitoring(
+	ctx context.Context,
+	log *zap.Logger,
+	reg prometheus.Registerer,
+	oomWatchInterval time.Duration,
+	masterPid func() int,
+	statusPoller *phpfpm.StatusPoller,
+	pools []phpfpm.Pool,
+) error {
+	cg, err := cgroup.Detect()
+	if err != nil {
+		return err
+	}
+
+	if err = reg.Register(cgroup.NewCollector(log, "phpfpm", cg)); err != nil {
+		return err
+	}
+
+	if oomWatchInterval <= 0 {
+		return nil
+	}
+
+	processes, err := fpmProcessLister(log, masterPid)
+	if err != nil {
+		return err
+	}
+
+	statusPoller.TrackProcesses()
+	go cgroup.NewOOMWatcher(log, cg, oomWatchInterval, processes, fpmExitChecker(statusPoller, pools)).Run(ctx)
+
+	return nil
+}
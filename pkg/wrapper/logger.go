@@ -0,0 +1,151 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mattn/go-isatty"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/zapx"
+)
+
+const (
+	logChannelWrapper = "wrapper"
+	logChannelErrlog  = "errlog"
+	logChannelSlowlog = "slowlog"
+	logChannelApp     = "app"
+)
+
+func parseTimeEncoder(format string) (zapcore.TimeEncoder, error) {
+	switch format {
+	case "iso8601":
+		return zapcore.ISO8601TimeEncoder, nil
+	case "epoch-millis":
+		return zapcore.EpochMillisTimeEncoder, nil
+	case "rfc3339nano":
+		return zapcore.RFC3339NanoTimeEncoder, nil
+	default:
+		return nil, fmt.Errorf("unknown log time format: %s", format)
+	}
+}
+
+func newZapEncoderConfig(cfg *Options) (zapcore.EncoderConfig, error) {
+	encodeTime, err := parseTimeEncoder(cfg.LogTimeFormat)
+	if err != nil {
+		return zapcore.EncoderConfig{}, err
+	}
+
+	result := zapcore.EncoderConfig{
+		TimeKey:        cfg.LogKeyTime,
+		LevelKey:       cfg.LogKeyLevel,
+		NameKey:        cfg.LogKeyChannel,
+		CallerKey:      "caller",
+		MessageKey:     cfg.LogKeyMessage,
+		StacktraceKey:  "stacktrace",
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     encodeTime,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+
+	return result, nil
+}
+
+func getZapEncoding() string {
+	if isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return "console"
+	}
+
+	return "json"
+}
+
+func createLoggerEncoder(eName string, encoderConfig zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	if eName == "auto" {
+		eName = getZapEncoding()
+	}
+
+	switch eName {
+	case "console":
+		return zapcore.NewConsoleEncoder(encoderConfig), nil
+	case "json":
+		return zapcore.NewJSONEncoder(encoderConfig), nil
+	case "logfmt":
+		return zapx.NewLogfmtEncoder(encoderConfig), nil
+	case "ecs":
+		return zapx.NewECSEncoder(encoderConfig), nil
+	case "gelf":
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("can't get host for gelf encoder: %w", err)
+		}
+
+		return zapx.NewGELFEncoder(encoderConfig, host), nil
+	default:
+		return nil, fmt.Errorf("unknown encoder: %s", eName)
+	}
+}
+
+// parseLogLevel parses level name or its number, e.g. "debug" or "-1"
+func parseLogLevel(s string) (zapcore.Level, error) {
+	level, err := zapcore.ParseLevel(s)
+	if err == nil {
+		return level, nil
+	}
+
+	levelRaw, err := strconv.Atoi(s)
+	if err != nil {
+		return level, fmt.Errorf("can't parse log level '%v': %w", s, err)
+	}
+
+	return zapcore.Level(levelRaw), nil
+}
+
+// createLogger creates logger with all levels enabled, channels limit it with their own levels by zapx.WithLevel
+func createLogger(cfg *Options, output zapcore.WriteSyncer) (*zap.Logger, error) {
+	encoderConfig, err := newZapEncoderConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := createLoggerEncoder(cfg.LogEncoder, encoderConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	allLevels := zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
+
+	return zap.New(zapcore.NewCore(enc, output, allLevels)), nil
+}
+
+// createChannelLoggers creates loggers of the wrapper itself, php-fpm errlog, slowlog and app logs,
+// every channel has its own level in the registry. Empty channel level means the wrapper level.
+func createChannelLoggers(cfg *Options, baseLog *zap.Logger, levels *zapx.LevelRegistry) (map[string]*zap.Logger, error) {
+	wrapperLevel, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	channelLevels := map[string]string{
+		logChannelWrapper: cfg.LogLevel,
+		logChannelErrlog:  cfg.LogLevelErrlog,
+		logChannelSlowlog: cfg.LogLevelSlowlog,
+		logChannelApp:     cfg.LogLevelApp,
+	}
+
+	result := make(map[string]*zap.Logger, len(channelLevels))
+	for channel, levelStr := range channelLevels {
+		level := wrapperLevel
+		if levelStr != "" {
+			if level, err = parseLogLevel(levelStr); err != nil {
+				return nil, err
+			}
+		}
+
+		result[channel] = zapx.WithLevel(baseLog, levels.Register(channel, level))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/zapx"
+)
+
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
+
+var debugToggleSignals = map[string]os.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGWINCH": syscall.SIGWINCH,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+}
+
+// parseDebugSignal returns nil for empty name, the signal isn't forwarded to php-fpm then
+func parseDebugSignal(name string) (os.Signal, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig, ok := debugToggleSignals[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported debug toggle signal: %s", name)
+	}
+
+	return sig, nil
+}
+
+func signalsToForward(debugSignal os.Signal) []os.Signal {
+	var result []os.Signal
+	for _, sig := range forwardedSignals {
+		if sig != debugSignal {
+			result = append(result, sig)
+		}
+	}
+
+	return result
+}
+
+func handleDebugToggle(log *zap.Logger, levels *zapx.LevelRegistry, signalCh <-chan os.Signal) {
+	for range signalCh {
+		if levels.ToggleDebug() {
+			log.Info("debug logging is toggled on")
+		} else {
+			log.Info("debug logging is toggled off")
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package wrapper
+
+import (
+	"time"
+
+	"github.com/FZambia/viper-lite"
+	"github.com/spf13/pflag"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/logproc"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+// Options configure the wrapper, every field is bound to the flag and env (with '-' replaced by '_')
+// of its mapstructure name by AddFlags
+type Options struct {
+	LogLevel   string `mapstructure:"log-level"`
+	LogEncoder string `mapstructure:"log-encoder"`
+
+	LogKeyTime    string `mapstructure:"log-key-time"`
+	LogKeyLevel   string `mapstructure:"log-key-level"`
+	LogKeyChannel string `mapstructure:"log-key-channel"`
+	LogKeyMessage string `mapstructure:"log-key-message"`
+	LogTimeFormat string `mapstructure:"log-time-format"`
+
+	LogLevelErrlog  string `mapstructure:"log-level-errlog"`
+	LogLevelSlowlog string `mapstructure:"log-level-slowlog"`
+	LogLevelApp     string `mapstructure:"log-level-app"`
+	LogDebugSignal  string `mapstructure:"log-debug-signal"`
+
+	LogProcessors string `mapstructure:"log-processors"`
+	// Processors are added after LogProcessors, they can't be set by flags
+	Processors []logproc.Processor `mapstructure:"-"`
+
+	RedactDetectors   []string `mapstructure:"redact-detectors"`
+	RedactParams      []string `mapstructure:"redact-params"`
+	RedactRules       string   `mapstructure:"redact-rules"`
+	RedactReplacement string   `mapstructure:"redact-replacement"`
+
+	FpmPath       string `mapstructure:"fpm"`
+	FpmConfigPath string `mapstructure:"fpm-config"`
+
+	FpmNoErrlogProxy  bool `mapstructure:"fpm-no-errlog"`
+	FpmNoSlowlogProxy bool `mapstructure:"fpm-no-slowlog"`
+	FpmSlowlogRequest bool `mapstructure:"fpm-slowlog-request"`
+
+	FpmSlowlogStripPrefix string `mapstructure:"fpm-slowlog-strip-prefix"`
+	FpmSlowlogLevel       string `mapstructure:"fpm-slowlog-level"`
+	FpmSlowlogMessage     string `mapstructure:"fpm-slowlog-message"`
+	FpmSlowlogPtr         bool   `mapstructure:"fpm-slowlog-ptr"`
+	FpmSlowlogPid         bool   `mapstructure:"fpm-slowlog-pid"`
+	FpmSlowlogPool        bool   `mapstructure:"fpm-slowlog-pool"`
+	FpmSlowlogTraceString bool   `mapstructure:"fpm-slowlog-trace-string"`
+
+	FpmSlowlogFlushTimeout time.Duration `mapstructure:"fpm-slowlog-flush-timeout"`
+
+	FpmSlowlogWindows   []string      `mapstructure:"fpm-slowlog-windows"`
+	FpmSlowlogMaxStacks int           `mapstructure:"fpm-slowlog-max-stacks"`
+	FpmSlowlogTop       int           `mapstructure:"fpm-slowlog-top"`
+	FpmSlowlogReport    time.Duration `mapstructure:"fpm-slowlog-report-interval"`
+	FpmSlowlogProfile   int           `mapstructure:"fpm-slowlog-profile-samples"`
+
+	FpmStatusTimeout   time.Duration `mapstructure:"fpm-status-timeout"`
+	FpmStatusKeepAlive bool          `mapstructure:"fpm-status-keepalive"`
+	FpmMetricsMode     string        `mapstructure:"fpm-metrics-mode"`
+	FpmStatusPoll      time.Duration `mapstructure:"fpm-status-poll-interval"`
+	FpmStatusHistory   int           `mapstructure:"fpm-status-history"`
+	FpmProcMetrics     bool          `mapstructure:"fpm-proc-metrics"`
+
+	FpmProfiler         string        `mapstructure:"fpm-profiler"`
+	FpmProfilerPhpspy   string        `mapstructure:"fpm-profiler-phpspy"`
+	FpmProfilerRate     int           `mapstructure:"fpm-profiler-rate"`
+	FpmProfilerDuration time.Duration `mapstructure:"fpm-profiler-max-duration"`
+
+	FpmOverrideConfig         string        `mapstructure:"fpm-override-config"`
+	FpmGenerateConfig         bool          `mapstructure:"fpm-generate-config"`
+	FpmAutosize               string        `mapstructure:"fpm-autosize"`
+	FpmAutosizeWorkerMemory   string        `mapstructure:"fpm-autosize-worker-memory"`
+	FpmAutosizeReservedMemory string        `mapstructure:"fpm-autosize-reserved-memory"`
+	FpmAutosizeWarmup         time.Duration `mapstructure:"fpm-autosize-warmup"`
+
+	CgroupMetrics  bool          `mapstructure:"cgroup-metrics"`
+	CgroupOOMWatch time.Duration `mapstructure:"cgroup-oom-watch-interval"`
+
+	// Logging proxy section
+	WrapperPipe    string `mapstructure:"wrapper-pipe"`
+	WrapperSocket  string `mapstructure:"wrapper-socket"`
+	LineBufferSize int    `mapstructure:"line-buffer-size"`
+
+	WrapperPoolSocket string `mapstructure:"wrapper-pool-socket"`
+	WrapperPoolPipe   string `mapstructure:"wrapper-pool-pipe"`
+	WrapperPoolEnv    bool   `mapstructure:"wrapper-pool-env"`
+
+	LogEnrich        bool     `mapstructure:"log-enrich"`
+	LogEnrichEnv     []string `mapstructure:"log-enrich-env"`
+	LogEnrichRequest bool     `mapstructure:"log-enrich-request"`
+
+	//
+	Listen      string `mapstructure:"listen"`
+	MetricsPath string `mapstructure:"metrics-path"`
+
+	HTTPAllow      []string `mapstructure:"http-allow"`
+	HTTPToken      string   `mapstructure:"http-token"`
+	FpmStatusProxy bool     `mapstructure:"fpm-status-proxy"`
+
+	FcgiGatewayListen string `mapstructure:"fcgi-gateway-listen"`
+	FcgiGatewayPool   string `mapstructure:"fcgi-gateway-pool"`
+	FcgiGatewayRoot   string `mapstructure:"fcgi-gateway-root"`
+	FcgiGatewayIndex  string `mapstructure:"fcgi-gateway-index"`
+
+	ShutdownDelay time.Duration `mapstructure:"shutdown-delay"`
+}
+
+// AddFlags defines flags of all options with their defaults
+func AddFlags(fs *pflag.FlagSet) {
+	fs.String("log-level", "-1", "Log level. -1 debug ")
+	fs.String("log-encoder", "auto", "Log encoder: auto, console, json, logfmt, ecs or gelf")
+	fs.String("log-key-time", "ts", "Time key of console, json and logfmt encoders")
+	fs.String("log-key-level", "level", "Level key of console, json and logfmt encoders")
+	fs.String("log-key-channel", "channel", "Channel key of console, json and logfmt encoders")
+	fs.String("log-key-message", "message", "Message key of console, json and logfmt encoders")
+	fs.String("log-time-format", "iso8601", "Time format of console, json and logfmt encoders: iso8601, epoch-millis or rfc3339nano")
+	fs.String("log-level-errlog", "", "php-fpm error log level, --log-level when empty")
+	fs.String("log-level-slowlog", "", "Slowlog level, --log-level when empty")
+	fs.String("log-level-app", "", "Level of structured app records, --log-level when empty")
+	fs.String("log-debug-signal", "SIGHUP", "Signal which toggles debug level of all log channels, set '' to disable")
+	fs.String("log-processors", "", "Log processors chain as json array or path to json file")
+
+	fs.StringSlice(
+		"redact-detectors",
+		[]string{redact.DetectorJWT, redact.DetectorAPIKey, redact.DetectorEmail, redact.DetectorParams},
+		"Secret detectors of slowlog paths, status page requests and redact log processors: "+
+			"jwt, api-key, email, card and params, set '' to disable",
+	)
+	fs.StringSlice("redact-params", redact.DefaultParams, "Names of query params redacted by the params detector")
+	fs.String("redact-rules", "", "Custom redact rules as json object of rule name to regexp or path to json file")
+	fs.String("redact-replacement", redact.DefaultReplacement, "Replacement of redacted values")
+
+	fs.StringP("fpm", "f", "", "path to php-fpm")
+	fs.StringP("fpm-config", "c", "/etc/php/php-fpm.conf", "path to php-fpm config file")
+
+	fs.Bool("fpm-no-errlog", false, "Disable php-fpm errlog parsing and proxy")
+	fs.Bool("fpm-no-slowlog", false, "Disable php-fpm slowlog parsing and proxy")
+	fs.Bool("fpm-slowlog-request", true, "Attach the slow request taken from the pool status page to slowlog records")
+	fs.String("fpm-slowlog-strip-prefix", "auto", "Prefix removed from slowlog paths: off, auto (common directory) or a fixed prefix")
+	fs.String("fpm-slowlog-level", "warn", "Level of slowlog records")
+	fs.String("fpm-slowlog-message", "slowlog", "Message of slowlog records, $pool, $pid, $script and $func are replaced")
+	fs.Bool("fpm-slowlog-ptr", false, "Add pointer address to slowlog trace frames")
+	fs.Bool("fpm-slowlog-pid", false, "Add worker pid to slowlog records")
+	fs.Bool("fpm-slowlog-pool", false, "Add pool to slowlog records, it's added by --log-enrich too")
+	fs.Bool("fpm-slowlog-trace-string", false, "Write slowlog trace as a single string with a frame per line")
+	fs.Duration("fpm-slowlog-flush-timeout", 25*time.Millisecond, "Delay after the last slowlog line when the entry is complete without the trailing empty line")
+	fs.StringSlice("fpm-slowlog-windows", []string{"1m", "5m", "15m"}, "Sliding windows of slowlog call path counts")
+	fs.Int("fpm-slowlog-max-stacks", 1000, "Max number of tracked slowlog call paths")
+	fs.Int("fpm-slowlog-top", 10, "Number of call paths in the periodic slowlog report")
+	fs.Duration("fpm-slowlog-report-interval", time.Minute, "Interval of slowlog top report, 0 disables it")
+	fs.Int("fpm-slowlog-profile-samples", 10000, "Number of kept slowlog stacks served as flame graph profiles, 0 disables it")
+
+	fs.Duration("fpm-status-timeout", time.Second, "php-fpm status page request timeout")
+	fs.Bool("fpm-status-keepalive", false, "Keep FastCGI connection to the status page open, it occupies one worker of the pool")
+	fs.String("fpm-metrics-mode", "json", "Status page format used for metrics: json, openmetrics (php 8.1+) or auto")
+	fs.Duration("fpm-status-poll-interval", 5*time.Second, "Interval of background status page polling, metrics are served from the last sample")
+	fs.Int("fpm-status-history", 12, "Number of kept status samples used for request rate and peak active processes")
+	fs.Bool("fpm-proc-metrics", true, "Export memory, cpu and fds of fpm master and workers read from procfs")
+
+	fs.String("fpm-profiler", "auto", "Stack sampler of /fpm/{pool}/profile: off, phpspy, status or auto (phpspy when found)")
+	fs.String("fpm-profiler-phpspy", "phpspy", "Path to phpspy")
+	fs.Int("fpm-profiler-rate", 99, "Samples per second, status sampler polls at most 10 times per second")
+	fs.Duration("fpm-profiler-max-duration", 5*time.Minute, "Max duration of one profile")
+
+	fs.String("fpm-override-config", "/tmp/docker-fpm-wrapper/php-fpm.conf", "Path of generated config which includes --fpm-config and overrides its values")
+	fs.Bool("fpm-generate-config", false, "Generate fpm config from FPM_GLOBAL_* and FPM_POOL_* env into --fpm-override-config")
+	fs.String("fpm-autosize", "off", "pm.max_children autosize by cgroup memory limit: off, log (only recommend) or apply")
+	fs.String("fpm-autosize-worker-memory", "", "Memory used by one worker, e.g. 64M; measured from warmed up workers when empty")
+	fs.String("fpm-autosize-reserved-memory", "64M", "Memory reserved for fpm master, the wrapper and opcache")
+	fs.Duration("fpm-autosize-warmup", time.Minute, "Delay before worker memory is measured")
+
+	fs.Bool("cgroup-metrics", true, "Export memory, cpu throttling and pids usage of the container cgroup")
+	fs.Duration("cgroup-oom-watch-interval", time.Second, "Interval of OOM kill checks, 0 disables OOM kill events")
+
+	// Logging proxy section
+	fs.StringP("wrapper-pipe", "p", "/tmp/fpm-wrapper-pipe", "path to logging pipe, set '' to disable")
+	fs.StringP("wrapper-socket", "s", "/tmp/fpm-wrapper.sock", "path to logging socket, set null to disable")
+	fs.Uint("line-buffer-size", 16*1024, "Max log line size (in bytes)")
+	fs.String("wrapper-pool-socket", "", "Path template of per pool logging socket, e.g. /tmp/fpm-wrapper-$pool.sock, set '' to disable")
+	fs.String("wrapper-pool-pipe", "", "Path template of per pool logging pipe, e.g. /tmp/fpm-wrapper-$pool.pipe, set '' to disable")
+	fs.Bool("wrapper-pool-env", true, "Pass per pool socket and pipe paths to workers with env[] of the override config")
+
+	fs.Bool("log-enrich", false, "Add container metadata to every app, errlog and slowlog record")
+	fs.StringSlice(
+		"log-enrich-env",
+		[]string{"pod=POD_NAME", "namespace=POD_NAMESPACE", "container=CONTAINER_NAME", "image_tag=IMAGE_TAG"},
+		"Static enrichment fields in form field=ENV_NAME",
+	)
+	fs.Bool("log-enrich-request", false, "Add current request of the socket peer worker from the last status page poll")
+
+	// Prom section
+	fs.String("listen", ":8080", "prometheus statistic addr")
+	fs.String("metrics-path", "/metrics", "prometheus statistic path")
+
+	fs.StringSlice("http-allow", []string{"127.0.0.0/8", "::1"}, "Networks allowed to use debug endpoints")
+	fs.String("http-token", "", "Bearer token allowed to use debug endpoints from any network")
+	fs.Bool("fpm-status-proxy", true, "Serve php-fpm status and ping pages on /fpm/{pool}/status and /fpm/{pool}/ping")
+
+	// FastCGI gateway section
+	fs.String("fcgi-gateway-listen", "", "HTTP to FastCGI gateway addr, set '' to disable")
+	fs.String("fcgi-gateway-pool", "", "Pool served by the gateway, the first pool by default")
+	fs.String("fcgi-gateway-root", "/var/www/html", "Gateway document root")
+	fs.String("fcgi-gateway-index", "index.php", "Gateway front controller script")
+
+	fs.Duration("shutdown-delay", 500*time.Millisecond, "Delay before process shutdown")
+}
+
+// DefaultOptions returns options with defaults of AddFlags flags
+func DefaultOptions() Options {
+	fs := pflag.NewFlagSet("wrapper", pflag.ContinueOnError)
+	AddFlags(fs)
+
+	v := viper.New()
+	if err := v.BindPFlags(fs); err != nil {
+		panic(err)
+	}
+
+	var opts Options
+	if err := v.UnmarshalExact(&opts); err != nil {
+		panic(err)
+	}
+
+	return opts
+}
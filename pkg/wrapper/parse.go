@@ -0,0 +1,135 @@
+package wrapper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
+	"github.com/code-tool/docker-fpm-wrapper/internal/zapx"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+// ParseErrlog writes records of the existing php-fpm error log read from r until it's exhausted or ctx is done.
+// Lines which can't be parsed are skipped and counted in phpfpm_errlog_parse_errors_total.
+func (w *Wrapper) ParseErrlog(ctx context.Context, r io.Reader) error {
+	parseErrors := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "phpfpm",
+		Name:      "errlog_parse_errors_total",
+		Help:      "The number of error log lines which couldn't be parsed",
+	})
+
+	scope := w.startScope()
+	defer scope.close()
+
+	if err := scope.Register(parseErrors); err != nil {
+		return err
+	}
+
+	// records are redacted by redact log processors
+	if err := scope.Register(w.redactor); err != nil {
+		return err
+	}
+
+	log := w.channelLogs[logChannelErrlog].Named("php-fpm")
+	parser := phpfpm.NewErrLogParser()
+	bufioReader := bufio.NewReaderSize(r, w.opts.LineBufferSize)
+
+	for ctx.Err() == nil {
+		entry, err := parser.ParseOne(bufioReader)
+		if errors.Is(err, phpfpm.ErrUnexpectedLogLine) {
+			parseErrors.Inc()
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ce := log.Check(zapx.MapFpmLogLevel(entry.Level), entry.Message); ce != nil {
+			ce.Time = entry.CreatedAt
+			ce.Write()
+		}
+	}
+
+	return nil
+}
+
+// ParseSlowlog writes records of the existing slowlog read from r until it's exhausted or ctx is done, entries are
+// passed to the slowlog sinks and served on /slowlog endpoints. The slowlog top of all entries is written at the end,
+// or every --fpm-slowlog-report-interval when follow is set.
+func (w *Wrapper) ParseSlowlog(ctx context.Context, r io.Reader, follow bool) error {
+	cfg := &w.opts
+	log := w.channelLogs[logChannelSlowlog].Named("php-fpm")
+
+	enc, err := createSlowlogEncoder(cfg)
+	if err != nil {
+		return err
+	}
+
+	aggregator, err := createSlowlogAggregator(cfg)
+	if err != nil {
+		return err
+	}
+
+	scope := w.startScope()
+	defer scope.close()
+
+	parseErrors := newSlowlogParseErrors()
+	if err = scope.Register(parseErrors); err != nil {
+		return err
+	}
+
+	if err = scope.Register(w.redactor); err != nil {
+		return err
+	}
+
+	if !follow {
+		aggregator.Replay()
+	}
+
+	sinks := append([]SlowlogSink{aggregator}, w.sinks...)
+	scope.Handle("/slowlog/top", aggregator)
+	if cfg.FpmSlowlogProfile > 0 {
+		profile := phpfpm.NewSlowlogProfile("/slowlog", cfg.FpmSlowlogProfile)
+		sinks = append(sinks, profile)
+		scope.Handle("/slowlog/stacks", profile)
+		scope.Handle("/slowlog/pprof", profile)
+	}
+
+	parser := phpfpm.NewSlowlogParser(phpfpm.SlowlogParserOptions{
+		FlushTimeout: cfg.FpmSlowlogFlushTimeout,
+		MaxLineSize:  cfg.LineBufferSize,
+		OnError: func(reason string, _ []byte) {
+			// the pool of the broken line is unknown
+			parseErrors.WithLabelValues("", reason).Inc()
+		},
+	})
+	enricher := enrich.NewNopEnricher()
+
+	if follow && cfg.FpmSlowlogReport > 0 {
+		go reportSlowlogTop(ctx, log, aggregator, cfg.FpmSlowlogReport, cfg.FpmSlowlogTop)
+	}
+
+	out := make(chan phpfpm.SlowlogEntry)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- parser.Parse(ctx, r, out)
+	}()
+
+	for {
+		select {
+		case err = <-errCh:
+			if !follow && aggregator.Added() > 0 {
+				logSlowlogTop(log, aggregator.Top(cfg.FpmSlowlogTop, 0))
+			}
+
+			return err
+		case entry := <-out:
+			writeSlowlogEntry(log, enc, w.redactor, sinks, enricher, nil, entry)
+		}
+	}
+}
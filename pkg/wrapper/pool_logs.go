@@ -0,0 +1,90 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/applog"
+	"github.com/code-tool/docker-fpm-wrapper/internal/breader"
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+const poolPlaceholder = "$pool"
+
+func poolPath(pathTemplate, poolName string) string {
+	return strings.ReplaceAll(pathTemplate, poolPlaceholder, poolName)
+}
+
+// startPoolLogListeners starts socket and pipe of every pool, the pool field is added to all their records.
+// When injectEnv is set, paths are passed to workers of the pool with env[] directives of the override config.
+// It returns stop func of the started sockets.
+func startPoolLogListeners(
+	ctx context.Context,
+	log *zap.Logger,
+	appLog *zap.Logger,
+	cfg *Options,
+	writer io.Writer,
+	pools []phpfpm.Pool,
+	enricher *enrich.Enricher,
+	override *phpfpm.ConfigOverride,
+	errCh chan error,
+) (func(), error) {
+	var listeners []*applog.SockDataListener
+	stop := func() {
+		for _, l := range listeners {
+			l.Stop()
+		}
+	}
+
+	rPool := breader.NewPool(cfg.LineBufferSize)
+
+	for _, pool := range pools {
+		poolEnricher := enricher.ForPool(pool.Name)
+
+		if cfg.WrapperPoolSocket != "" {
+			sockPath := poolPath(cfg.WrapperPoolSocket, pool.Name)
+			l := applog.NewSockDataListener(
+				log.Named("socket").With(zap.String("pool", pool.Name)),
+				sockPath,
+				rPool,
+				writer,
+				applog.NewRecordWriter(appLog.With(poolEnricher.Fields()...)),
+				poolEnricher,
+				errCh,
+			)
+
+			if err := l.Start(); err != nil {
+				stop()
+				return nil, fmt.Errorf("pool %s socket: %w", pool.Name, err)
+			}
+			listeners = append(listeners, l)
+
+			if cfg.WrapperPoolEnv {
+				override.SetPool(pool.Name, "env[FPM_WRAPPER_SOCK]", "unix://"+sockPath)
+			}
+		}
+
+		if cfg.WrapperPoolPipe != "" {
+			pipePath := poolPath(cfg.WrapperPoolPipe, pool.Name)
+			pipe, err := createFIFOByPathCtx(ctx, pipePath)
+			if err != nil {
+				stop()
+				return nil, fmt.Errorf("pool %s pipe: %w", pool.Name, err)
+			}
+
+			go applog.NewPipeProxy(log.Named("pipe-proxy").With(zap.String("pool", pool.Name)), writer, poolEnricher).
+				Proxy(pipe)
+
+			if cfg.WrapperPoolEnv {
+				override.SetPool(pool.Name, "env[FPM_WRAPPER_PIPE]", pipePath)
+			}
+		}
+	}
+
+	return stop, nil
+}
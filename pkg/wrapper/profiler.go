@@ -0,0 +1,42 @@
+package wrapper
+
+import (
+	"fmt"
+	"os/exec"
+
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+const (
+	profilerOff    = "off"
+	profilerAuto   = "auto"
+	profilerPhpspy = "phpspy"
+	profilerStatus = "status"
+)
+
+// createStackSampler returns nil when the profiler is off, auto mode uses phpspy when it's found
+func createStackSampler(log *zap.Logger, cfg *Options) (phpfpm.StackSampler, error) {
+	switch cfg.FpmProfiler {
+	case profilerOff:
+		return nil, nil
+	case profilerStatus:
+		return phpfpm.NewStatusSampler(cfg.FpmProfilerRate), nil
+	case profilerPhpspy, profilerAuto:
+		path, err := exec.LookPath(cfg.FpmProfilerPhpspy)
+		if err == nil {
+			return phpfpm.NewPhpspySampler(path, cfg.FpmProfilerRate), nil
+		}
+
+		if cfg.FpmProfiler == profilerPhpspy {
+			return nil, err
+		}
+
+		log.Info("phpspy isn't found, profiler samples status page", zap.String("phpspy", cfg.FpmProfilerPhpspy))
+
+		return phpfpm.NewStatusSampler(cfg.FpmProfilerRate), nil
+	default:
+		return nil, fmt.Errorf("unknown profiler mode: %s", cfg.FpmProfiler)
+	}
+}
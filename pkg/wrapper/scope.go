@@ -0,0 +1,88 @@
+package wrapper
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// runScope holds endpoints and collectors added by one Run or parse call, they are removed by close,
+// so the wrapper can be run again. It registers collectors on the wrapper registry.
+type runScope struct {
+	w   *Wrapper
+	mux *http.ServeMux
+
+	mu         sync.Mutex
+	collectors []prometheus.Collector
+}
+
+// startScope creates scope which endpoints are served by the wrapper until close
+func (w *Wrapper) startScope() *runScope {
+	s := &runScope{w: w, mux: http.NewServeMux()}
+	w.runMux.Store(s.mux)
+
+	return s
+}
+
+func (w *Wrapper) serveRun(rw http.ResponseWriter, r *http.Request) {
+	mux := w.runMux.Load()
+	if mux == nil {
+		http.NotFound(rw, r)
+		return
+	}
+
+	mux.ServeHTTP(rw, r)
+}
+
+// Handle registers debug endpoint of the scope, it's available only to allowed clients
+func (s *runScope) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, s.w.access.Wrap(h))
+}
+
+func (s *runScope) Register(c prometheus.Collector) error {
+	if err := s.w.registry.Register(c); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.collectors = append(s.collectors, c)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *runScope) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := s.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (s *runScope) Unregister(c prometheus.Collector) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.collectors {
+		if s.collectors[i] == c {
+			s.collectors = append(s.collectors[:i], s.collectors[i+1:]...)
+			break
+		}
+	}
+
+	return s.w.registry.Unregister(c)
+}
+
+// close unregisters collectors and stops serving endpoints of the scope
+func (s *runScope) close() {
+	s.w.runMux.CompareAndSwap(s.mux, nil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.collectors {
+		s.w.registry.Unregister(c)
+	}
+	s.collectors = nil
+}
@@ -0,0 +1,253 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
+	"github.com/code-tool/docker-fpm-wrapper/internal/zapx"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+// SlowlogSink receives every parsed slowlog entry, e.g. aggregator and profile
+type SlowlogSink interface {
+	Add(entry phpfpm.SlowlogEntry)
+}
+
+type poolProcessFinder interface {
+	FindPoolProcess(poolName string, pid int) (phpfpm.ProcessStatus, bool)
+}
+
+func encodeSlowlogEntry(
+	enc *zapx.SlowlogEncoder,
+	enricher *enrich.Enricher,
+	requests poolProcessFinder,
+	entry phpfpm.SlowlogEntry,
+) []zap.Field {
+	fields := enc.Encode(entry)
+	if !enc.IncludesPool() {
+		fields = append(fields, enricher.PoolFields(entry.PoolName)...)
+	}
+
+	if requests == nil {
+		return fields
+	}
+
+	if proc, ok := requests.FindPoolProcess(entry.PoolName, entry.Pid); ok {
+		fields = append(fields, zapx.EncodeRequest(proc)...)
+	}
+
+	return fields
+}
+
+func newSlowlogParseErrors() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "phpfpm",
+			Name:      "slowlog_parse_errors_total",
+			Help:      "The number of slowlog lines which couldn't be parsed by reason",
+		},
+		[]string{"pool_name", "reason"},
+	)
+}
+
+// writeSlowlogEntry redacts the entry, passes it to sinks and writes its record
+func writeSlowlogEntry(
+	log *zap.Logger,
+	enc *zapx.SlowlogEncoder,
+	redactor *redact.Redactor,
+	sinks []SlowlogSink,
+	enricher *enrich.Enricher,
+	requests poolProcessFinder,
+	entry phpfpm.SlowlogEntry,
+) {
+	entry.Redact(redactor)
+
+	for _, sink := range sinks {
+		sink.Add(entry)
+	}
+
+	if ce := log.Check(enc.Level(), enc.Message(entry)); ce != nil {
+		ce.Time = entry.CreatedAt
+		ce.Write(encodeSlowlogEntry(enc, enricher, requests, entry)...)
+	}
+}
+
+func startSlowlogProxyForPool(
+	ctx context.Context,
+	log *zap.Logger,
+	pool phpfpm.Pool,
+	flushTimeout time.Duration,
+	parseErrors *prometheus.CounterVec,
+	out chan phpfpm.SlowlogEntry,
+) error {
+	fifoF, err := createFIFOByPathCtx(ctx, pool.SlowlogPath)
+	if err != nil {
+		return err
+	}
+
+	slowLogParser := phpfpm.NewSlowlogParser(phpfpm.SlowlogParserOptions{
+		MaxTraceLen:  pool.RequestSlowlogTraceDepth,
+		FlushTimeout: flushTimeout,
+		OnError: func(reason string, line []byte) {
+			parseErrors.WithLabelValues(pool.Name, reason).Inc()
+			log.Debug("can't parse php-fpm slowlog line",
+				zap.String("pool", pool.Name), zap.String("reason", reason), zap.ByteString("line", line))
+		},
+	})
+	go func() {
+		if err := slowLogParser.Parse(ctx, fifoF, out); err != nil {
+			log.Error("can't parse php-fpm slowlog entry", zap.Error(err))
+		}
+
+		_, _ = io.Copy(io.Discard, fifoF)
+	}()
+
+	return nil
+}
+
+func createSlowlogEncoder(cfg *Options) (*zapx.SlowlogEncoder, error) {
+	opts := zapx.DefaultSlowlogOptions()
+	opts.StripPrefix = cfg.FpmSlowlogStripPrefix
+	opts.Message = cfg.FpmSlowlogMessage
+	opts.IncludePtr = cfg.FpmSlowlogPtr
+	opts.IncludePid = cfg.FpmSlowlogPid
+	opts.IncludePool = cfg.FpmSlowlogPool
+	opts.TraceString = cfg.FpmSlowlogTraceString
+
+	level, err := parseLogLevel(cfg.FpmSlowlogLevel)
+	if err != nil {
+		return nil, err
+	}
+	opts.Level = level
+
+	return zapx.NewSlowlogEncoder(opts), nil
+}
+
+func startSlowlogProxies(
+	ctx context.Context,
+	log *zap.Logger,
+	reg prometheus.Registerer,
+	slowlogEnc *zapx.SlowlogEncoder,
+	redactor *redact.Redactor,
+	sinks []SlowlogSink,
+	enricher *enrich.Enricher,
+	requests poolProcessFinder,
+	pools []phpfpm.Pool,
+	flushTimeout time.Duration,
+) error {
+	parseErrors := newSlowlogParseErrors()
+	if err := reg.Register(parseErrors); err != nil {
+		return err
+	}
+
+	outCh := make(chan phpfpm.SlowlogEntry)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case entry := <-outCh:
+				writeSlowlogEntry(log, slowlogEnc, redactor, sinks, enricher, requests, entry)
+			}
+		}
+	}()
+
+	for _, pool := range pools {
+		if pool.SlowlogPath == "" {
+			continue
+		}
+
+		if err := startSlowlogProxyForPool(ctx, log, pool, flushTimeout, parseErrors, outCh); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createSlowlogAggregator(cfg *Options) (*phpfpm.SlowlogAggregator, error) {
+	windows := make([]time.Duration, 0, len(cfg.FpmSlowlogWindows))
+	for _, windowStr := range cfg.FpmSlowlogWindows {
+		window, err := time.ParseDuration(windowStr)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse slowlog window '%s': %w", windowStr, err)
+		}
+
+		windows = append(windows, window)
+	}
+
+	return phpfpm.NewSlowlogAggregator(windows, cfg.FpmSlowlogMaxStacks), nil
+}
+
+func slowlogTopField(report phpfpm.SlowlogReport) zap.Field {
+	return zap.Array("top", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for i := range report.Stacks {
+			stack := &report.Stacks[i]
+			err := enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+				enc.AddString("signature", stack.Signature)
+				enc.AddInt("count", stack.Counts[report.Window])
+				enc.AddInt("total", stack.Total)
+				enc.AddString("script", stack.Script)
+				if len(stack.Frames) > 0 {
+					enc.AddString("func", stack.Frames[0].Func)
+					enc.AddString("path", stack.Frames[0].Path)
+				}
+
+				return enc.AddArray("example_pids", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+					for _, pid := range stack.ExamplePids {
+						enc.AppendInt(pid)
+					}
+
+					return nil
+				}))
+			}))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+// reportSlowlogTop logs the top of slow call paths over the shortest window every interval,
+// nothing is logged when there were no slowlog entries since the previous report
+func reportSlowlogTop(ctx context.Context, log *zap.Logger, aggregator *phpfpm.SlowlogAggregator, interval time.Duration, n int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	window := aggregator.Windows()[0]
+	reported := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		added := aggregator.Added()
+		if added == reported {
+			continue
+		}
+		reported = added
+
+		logSlowlogTop(log, aggregator.Top(n, window))
+	}
+}
+
+func logSlowlogTop(log *zap.Logger, report phpfpm.SlowlogReport) {
+	log.Info("slowlog top",
+		zap.String("window", report.Window),
+		zap.Int("dropped", report.Dropped),
+		slowlogTopField(report),
+	)
+}
@@ -0,0 +1,55 @@
+package wrapper
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+)
+
+// generateFpmConfig renders fpm config from FPM_GLOBAL_* and FPM_POOL_* env on top of --fpm-config,
+// when it doesn't exist a standalone config is rendered. Logs and status pages are wired to the wrapper proxies.
+func generateFpmConfig(log *zap.Logger, cfg *Options, env []string) (*phpfpm.ConfigOverride, phpfpm.Config, error) {
+	dir := filepath.Dir(cfg.FpmOverrideConfig)
+
+	baseConfig := cfg.FpmConfigPath
+	if _, err := os.Stat(baseConfig); errors.Is(err, fs.ErrNotExist) {
+		baseConfig = ""
+	}
+
+	override := phpfpm.NewConfigOverride(baseConfig)
+	for _, name := range override.ApplyEnv(env) {
+		log.Warn("unknown php-fpm directive in env", zap.String("env", name))
+	}
+
+	if baseConfig == "" {
+		override.SetStandaloneDefaults(dir)
+	}
+
+	fpmConfig, err := phpfpm.ParseConfigOverride(override)
+	if err != nil {
+		return nil, fpmConfig, err
+	}
+
+	override.WireProxies(dir, fpmConfig.Pools)
+	if fpmConfig, err = phpfpm.ParseConfigOverride(override); err != nil {
+		return nil, fpmConfig, err
+	}
+
+	for _, pool := range fpmConfig.Pools {
+		if pool.RequestSlowlogTimeout == 0 {
+			log.Warn(
+				"slowlog is disabled, set request_slowlog_timeout to enable it",
+				zap.String("pool", pool.Name),
+				zap.String("env", "FPM_POOL_"+strings.ToUpper(pool.Name)+"_REQUEST_SLOWLOG_TIMEOUT"),
+			)
+		}
+	}
+
+	return override, fpmConfig, override.WriteFile(cfg.FpmOverrideConfig)
+}
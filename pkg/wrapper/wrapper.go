@@ -0,0 +1,537 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/applog"
+	"github.com/code-tool/docker-fpm-wrapper/internal/breader"
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
+	"github.com/code-tool/docker-fpm-wrapper/internal/httpx"
+	"github.com/code-tool/docker-fpm-wrapper/internal/zapx"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/fcgi"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/logproc"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+// RunInfo describes the started php-fpm for start hooks
+type RunInfo struct {
+	Log           *zap.Logger
+	Pools         []phpfpm.Pool
+	StatusClients []*phpfpm.StatusClient
+	// MasterPid returns pid of php-fpm master process, 0 before start
+	MasterPid func() int
+}
+
+// StartHook is called by Run after php-fpm is started, the error stops the wrapper
+type StartHook func(ctx context.Context, info RunInfo) error
+
+// Wrapper runs php-fpm with log proxies, metrics and debug endpoints. Hooks have to be added before Run.
+// Wrapper is the http handler of metrics and debug endpoints served on --listen by Run.
+// Metrics are registered on the wrapper own registry, so several wrappers don't share them.
+type Wrapper struct {
+	opts   Options
+	output zapcore.WriteSyncer
+	// lineOutput is output of raw app log lines passed through log processors
+	lineOutput io.Writer
+
+	baseLog     *zap.Logger
+	levels      *zapx.LevelRegistry
+	channelLogs map[string]*zap.Logger
+
+	access   *httpx.AccessControl
+	mux      *http.ServeMux
+	registry *prometheus.Registry
+	// runMux serves endpoints of the current Run or parse call
+	runMux atomic.Pointer[http.ServeMux]
+	// redactor replaces secrets in slowlog paths, status page requests and records of redact log processors
+	redactor *redact.Redactor
+
+	sinks      []SlowlogSink
+	collectors []prometheus.Collector
+	startHooks []StartHook
+}
+
+// New creates wrapper which writes logs to output, stderr when it's nil
+func New(opts Options, output io.Writer) (*Wrapper, error) {
+	if output == nil {
+		output = os.Stderr
+	}
+
+	w := &Wrapper{
+		opts:     opts,
+		output:   zapcore.Lock(zapcore.AddSync(output)),
+		levels:   zapx.NewLevelRegistry(),
+		mux:      http.NewServeMux(),
+		registry: prometheus.NewRegistry(),
+	}
+	w.registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	var err error
+	if w.access, err = httpx.NewAccessControl(opts.HTTPAllow, opts.HTTPToken); err != nil {
+		return nil, fmt.Errorf("can't create http access control: %w", err)
+	}
+
+	if w.baseLog, err = createLogger(&w.opts, w.output); err != nil {
+		return nil, fmt.Errorf("can't create logger: %w", err)
+	}
+
+	if w.redactor, err = createRedactor(&w.opts); err != nil {
+		return nil, fmt.Errorf("can't create redactor: %w", err)
+	}
+
+	chain, err := createLogProcessors(&w.opts, w.redactor)
+	if err != nil {
+		return nil, err
+	}
+
+	w.lineOutput = w.output
+	if len(chain) > 0 {
+		w.baseLog = w.baseLog.WithOptions(logproc.WrapCore(chain))
+		w.lineOutput = logproc.NewLineWriter(w.output, chain, logChannelApp)
+	}
+
+	if w.channelLogs, err = createChannelLoggers(&w.opts, w.baseLog, w.levels); err != nil {
+		return nil, fmt.Errorf("can't create logger: %w", err)
+	}
+
+	w.mux.Handle(opts.MetricsPath, promhttp.InstrumentMetricHandler(
+		w.registry, promhttp.HandlerFor(w.registry, promhttp.HandlerOpts{}),
+	))
+	w.mux.HandleFunc("/", w.serveRun)
+	w.Handle("/loglevel", w.levels.Handler("/loglevel"))
+	w.Handle("/loglevel/", w.levels.Handler("/loglevel"))
+
+	return w, nil
+}
+
+// Logger returns the logger of the wrapper channel
+func (w *Wrapper) Logger() *zap.Logger {
+	return w.channelLogs[logChannelWrapper]
+}
+
+// Sync flushes buffered log records
+func (w *Wrapper) Sync() error {
+	return w.baseLog.Sync()
+}
+
+// AddSlowlogSink adds sink of slowlog entries of all pools
+func (w *Wrapper) AddSlowlogSink(sink SlowlogSink) {
+	w.sinks = append(w.sinks, sink)
+}
+
+// AddCollector adds prometheus collector registered by Run for the time it runs
+func (w *Wrapper) AddCollector(collector prometheus.Collector) {
+	w.collectors = append(w.collectors, collector)
+}
+
+// Registry returns the registry of metrics served on --metrics-path
+func (w *Wrapper) Registry() *prometheus.Registry {
+	return w.registry
+}
+
+// Handle adds debug endpoint, it's available only from --http-allow networks or with --http-token
+func (w *Wrapper) Handle(pattern string, h http.Handler) {
+	w.mux.Handle(pattern, w.access.Wrap(h))
+}
+
+func (w *Wrapper) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mux.ServeHTTP(rw, r)
+}
+
+// OnStart adds hook called after php-fpm is started
+func (w *Wrapper) OnStart(hook StartHook) {
+	w.startHooks = append(w.startHooks, hook)
+}
+
+// createLogProcessors returns processors configured by --log-processors followed by Options.Processors,
+// redact processors use the wrapper redactor
+func createLogProcessors(cfg *Options, redactor *redact.Redactor) (logproc.Chain, error) {
+	var chain logproc.Chain
+	if cfg.LogProcessors != "" {
+		configs, err := logproc.LoadConfig(cfg.LogProcessors)
+		if err != nil {
+			return nil, err
+		}
+
+		if chain, err = logproc.NewChain(configs, redactor); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(chain, cfg.Processors...), nil
+}
+
+func createRedactor(cfg *Options) (*redact.Redactor, error) {
+	opts := redact.Options{Params: cfg.RedactParams, Replacement: cfg.RedactReplacement}
+	for _, detector := range cfg.RedactDetectors {
+		if detector != "" {
+			opts.Detectors = append(opts.Detectors, detector)
+		}
+	}
+
+	if cfg.RedactRules != "" {
+		var err error
+		if opts.Rules, err = redact.LoadRules(cfg.RedactRules); err != nil {
+			return nil, err
+		}
+	}
+
+	return redact.New(opts)
+}
+
+// createEnricher creates enricher of log records, requests of peer workers are taken from the last poll
+// of statusPoller, so the log path never waits for the status page
+func createEnricher(cfg *Options, statusPoller *phpfpm.StatusPoller) *enrich.Enricher {
+	if !cfg.LogEnrich {
+		return enrich.NewNopEnricher()
+	}
+
+	var processes enrich.ProcessFinder
+	if cfg.LogEnrichRequest {
+		statusPoller.TrackProcesses()
+		processes = statusPoller
+	}
+
+	return enrich.NewEnricher(cfg.LogEnrichEnv, processes)
+}
+
+func createFcgiGateway(cfg *Options, pools []phpfpm.Pool) (*fcgi.Gateway, error) {
+	for _, pool := range pools {
+		if cfg.FcgiGatewayPool != "" && pool.Name != cfg.FcgiGatewayPool {
+			continue
+		}
+
+		net, addr := pool.ListenAddr()
+		client := fcgi.NewClient(net, addr, fcgi.Options{DialTimeout: time.Second})
+
+		return fcgi.NewGateway(client, cfg.FcgiGatewayRoot, cfg.FcgiGatewayIndex), nil
+	}
+
+	return nil, fmt.Errorf("pool '%s' not found", cfg.FcgiGatewayPool)
+}
+
+// fpmStopTimeout limits the wait for php-fpm exit when Run fails after php-fpm is started
+const fpmStopTimeout = 10 * time.Second
+
+// stopFpm quits php-fpm and waits until it exits, php-fpm is killed when it doesn't exit in fpmStopTimeout
+func stopFpm(log *zap.Logger, fpmProcess *phpfpm.Process, exitCodeCh <-chan int) {
+	_ = fpmProcess.Signal(syscall.SIGQUIT)
+
+	select {
+	case <-exitCodeCh:
+		return
+	case <-time.After(fpmStopTimeout):
+	}
+
+	log.Error("php-fpm didn't exit after SIGQUIT, killing it", zap.Duration("timeout", fpmStopTimeout))
+	_ = fpmProcess.Signal(syscall.SIGKILL)
+
+	select {
+	case <-exitCodeCh:
+	case <-time.After(time.Second):
+	}
+}
+
+// Run starts php-fpm with fpmArgs and serves it until php-fpm exits, the exit code of php-fpm is returned.
+// When ctx is done php-fpm is stopped gracefully as on SIGTERM.
+func (w *Wrapper) Run(ctx context.Context, fpmArgs ...string) (int, error) {
+	cfg := &w.opts
+	log := w.Logger()
+
+	if cfg.FpmPath == "" {
+		return 1, errors.New("php-fpm path not set")
+	}
+
+	errCh := make(chan error, 1)
+	ctx, cancelCtx := context.WithCancel(ctx)
+	defer cancelCtx()
+
+	scope := w.startScope()
+	defer scope.close()
+
+	env := os.Environ()
+
+	fpmConfigPath := cfg.FpmConfigPath
+	configOverride := phpfpm.NewConfigOverride(cfg.FpmConfigPath)
+
+	var (
+		fpmConfig phpfpm.Config
+		err       error
+	)
+	if cfg.FpmGenerateConfig {
+		configOverride, fpmConfig, err = generateFpmConfig(log, cfg, env)
+		if err != nil {
+			return 1, fmt.Errorf("can't generate fpm config: %w", err)
+		}
+
+		fpmConfigPath = cfg.FpmOverrideConfig
+	} else if fpmConfig, err = phpfpm.ParseConfig(cfg.FpmConfigPath); err != nil {
+		return 1, fmt.Errorf("can't parse fpm config: %w", err)
+	}
+
+	metricsMode, err := phpfpm.ParseMetricsMode(cfg.FpmMetricsMode)
+	if err != nil {
+		return 1, fmt.Errorf("can't create prometheus collector: %w", err)
+	}
+
+	debugSignal, err := parseDebugSignal(cfg.LogDebugSignal)
+	if err != nil {
+		return 1, fmt.Errorf("can't set up debug toggle: %w", err)
+	}
+
+	stackSampler, err := createStackSampler(log.Named("profiler"), cfg)
+	if err != nil {
+		return 1, fmt.Errorf("can't create profiler: %w", err)
+	}
+
+	var gateway *fcgi.Gateway
+	if cfg.FcgiGatewayListen != "" {
+		if gateway, err = createFcgiGateway(cfg, fpmConfig.Pools); err != nil {
+			return 1, fmt.Errorf("can't create FastCGI gateway: %w", err)
+		}
+	}
+
+	statusClients := phpfpm.NewStatusClients(fpmConfig.Pools, fcgi.Options{
+		DialTimeout: cfg.FpmStatusTimeout,
+		Timeout:     cfg.FpmStatusTimeout,
+		KeepAlive:   cfg.FpmStatusKeepAlive,
+	})
+	for _, client := range statusClients {
+		client.SetRedactor(w.redactor)
+	}
+	promMetrics := phpfpm.NewPromMetrics()
+	statusPoller := phpfpm.NewStatusPoller(
+		log.Named("status-poller"), promMetrics, statusClients, metricsMode, cfg.FpmStatusPoll, cfg.FpmStatusHistory,
+	)
+	enricher := createEnricher(cfg, statusPoller)
+
+	if cfg.WrapperSocket != "null" {
+		env = append(env, fmt.Sprintf("FPM_WRAPPER_SOCK=unix://%s", cfg.WrapperSocket))
+		sockDataListener := applog.NewSockDataListener(
+			log.Named("socket"),
+			cfg.WrapperSocket,
+			breader.NewPool(cfg.LineBufferSize),
+			w.lineOutput,
+			applog.NewRecordWriter(w.channelLogs[logChannelApp].With(enricher.Fields()...)),
+			enricher,
+			errCh,
+		)
+
+		if err = sockDataListener.Start(); err != nil {
+			return 1, fmt.Errorf("can't start listen: %w", err)
+		}
+
+		defer sockDataListener.Stop()
+	}
+
+	if cfg.WrapperPipe != "" {
+		env = append(env, fmt.Sprintf("FPM_WRAPPER_PIPE=%s", cfg.WrapperPipe))
+
+		wrapperPipe, err := createFIFOByPathCtx(ctx, cfg.WrapperPipe)
+		if err != nil {
+			return 1, fmt.Errorf("can't create pipe %s: %w", cfg.WrapperPipe, err)
+		}
+
+		go applog.NewPipeProxy(log.Named("pipe-proxy"), w.lineOutput, enricher).Proxy(wrapperPipe)
+	}
+
+	if cfg.WrapperPoolSocket != "" || cfg.WrapperPoolPipe != "" {
+		stopPoolLogs, err := startPoolLogListeners(
+			ctx, log, w.channelLogs[logChannelApp], cfg, w.lineOutput, fpmConfig.Pools, enricher, configOverride, errCh,
+		)
+		if err != nil {
+			return 1, fmt.Errorf("can't start pool log listeners: %w", err)
+		}
+		defer stopPoolLogs()
+
+		if cfg.WrapperPoolEnv {
+			if err = configOverride.WriteFile(cfg.FpmOverrideConfig); err != nil {
+				return 1, fmt.Errorf("can't write fpm override config: %w", err)
+			}
+
+			fpmConfigPath = cfg.FpmOverrideConfig
+		}
+	}
+
+	if !cfg.FpmNoErrlogProxy && fpmConfig.ErrorLog != "syslog" {
+		errlogLog := w.channelLogs[logChannelErrlog].Named("php-fpm").With(enricher.Fields()...)
+		if err := startErrLogProxy(ctx, errlogLog, fpmConfig.ErrorLog); err != nil {
+			return 1, fmt.Errorf("can't start err_log proxy %s: %w", fpmConfig.ErrorLog, err)
+		}
+	}
+
+	var slowlogAggregator *phpfpm.SlowlogAggregator
+	var slowlogProfile *phpfpm.SlowlogProfile
+	if !cfg.FpmNoSlowlogProxy {
+		// slow requests are looked up in the last poll, the slowlog writer never waits for the status page
+		var requests poolProcessFinder
+		if cfg.FpmSlowlogRequest {
+			statusPoller.TrackProcesses()
+			requests = statusPoller
+		}
+
+		slowlogEnc, err := createSlowlogEncoder(cfg)
+		if err != nil {
+			return 1, fmt.Errorf("can't create slowlog encoder: %w", err)
+		}
+
+		slowlogAggregator, err = createSlowlogAggregator(cfg)
+		if err != nil {
+			return 1, fmt.Errorf("can't create slowlog aggregator: %w", err)
+		}
+
+		sinks := append([]SlowlogSink{slowlogAggregator}, w.sinks...)
+		if cfg.FpmSlowlogProfile > 0 {
+			slowlogProfile = phpfpm.NewSlowlogProfile("/slowlog", cfg.FpmSlowlogProfile)
+			sinks = append(sinks, slowlogProfile)
+		}
+
+		slowlogLog := w.channelLogs[logChannelSlowlog].Named("php-fpm").With(enricher.Fields()...)
+		err = startSlowlogProxies(
+			ctx, slowlogLog, scope, slowlogEnc, w.redactor, sinks, enricher, requests, fpmConfig.Pools, cfg.FpmSlowlogFlushTimeout,
+		)
+		if err != nil {
+			return 1, fmt.Errorf("can't start slowlog proxies: %w", err)
+		}
+
+		if cfg.FpmSlowlogReport > 0 {
+			go reportSlowlogTop(ctx, slowlogLog, slowlogAggregator, cfg.FpmSlowlogReport, cfg.FpmSlowlogTop)
+		}
+	}
+
+	var autosize *autosizer
+	if cfg.FpmAutosize != autosizeOff {
+		if autosize, err = newAutosizer(log.Named("autosize"), cfg, fpmConfig.Pools, configOverride); err != nil {
+			log.Error("Can't autosize pools", zap.Error(err))
+		} else if fpmConfigPath, err = autosize.prepare(fpmConfigPath); err != nil {
+			log.Error("Can't autosize pools", zap.Error(err))
+			autosize = nil
+		}
+	}
+
+	fpmProcess := phpfpm.
+		NewProcess(log, cfg.FpmPath, fpmConfigPath, os.Stdout, w.output, cfg.ShutdownDelay, env, fpmArgs...)
+
+	if err = fpmProcess.Start(); err != nil {
+		return 1, fmt.Errorf("can't start php-fpm: %w", err)
+	}
+
+	if autosize != nil {
+		go autosize.measure(ctx, fpmProcess)
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, signalsToForward(debugSignal)...)
+	defer signal.Stop(signalCh)
+	go fpmProcess.HandleSignal(signalCh)
+
+	fpmExitCodeCh := make(chan int, 1)
+	go func() {
+		fpmExitCodeCh <- fpmProcess.Wait(errCh)
+	}()
+
+	scope.MustRegister(phpfpm.NewPromCollector(promMetrics, statusPoller), w.redactor)
+
+	if cfg.FpmProcMetrics {
+		procCollector, err := phpfpm.NewProcCollector(log.Named("proc-collector"), fpmProcess.Pid, statusPoller)
+		if err != nil {
+			log.Warn("procfs isn't available, process metrics are disabled", zap.Error(err))
+		} else {
+			statusPoller.TrackProcesses()
+			scope.MustRegister(procCollector)
+		}
+	}
+
+	if cfg.CgroupMetrics {
+		err = startCgroupMonitoring(
+			ctx, log.Named("cgroup"), scope, cfg.CgroupOOMWatch, fpmProcess.Pid, statusPoller, fpmConfig.Pools,
+		)
+		if err != nil {
+			log.Warn("cgroup isn't available, cgroup metrics are disabled", zap.Error(err))
+		}
+	}
+
+	go statusPoller.Run(ctx)
+
+	for _, collector := range w.collectors {
+		if err = scope.Register(collector); err != nil {
+			stopFpm(log, fpmProcess, fpmExitCodeCh)
+			return 1, fmt.Errorf("can't register collector: %w", err)
+		}
+	}
+
+	info := RunInfo{Log: log, Pools: fpmConfig.Pools, StatusClients: statusClients, MasterPid: fpmProcess.Pid}
+	for _, hook := range w.startHooks {
+		if err = hook(ctx, info); err != nil {
+			stopFpm(log, fpmProcess, fpmExitCodeCh)
+			return 1, fmt.Errorf("start hook: %w", err)
+		}
+	}
+
+	if debugSignal != nil {
+		debugSignalCh := make(chan os.Signal, 1)
+		signal.Notify(debugSignalCh, debugSignal)
+		defer signal.Stop(debugSignalCh)
+		go handleDebugToggle(log, w.levels, debugSignalCh)
+	}
+
+	if cfg.FpmStatusProxy {
+		scope.Handle("/fpm/", phpfpm.NewStatusHandler(statusClients))
+	}
+	if slowlogAggregator != nil {
+		scope.Handle("/slowlog/top", slowlogAggregator)
+	}
+	if stackSampler != nil {
+		scope.Handle("GET /fpm/{pool}/profile", phpfpm.NewProfileHandler(statusClients, stackSampler, cfg.FpmProfilerDuration))
+	}
+	if slowlogProfile != nil {
+		scope.Handle("/slowlog/stacks", slowlogProfile)
+		scope.Handle("/slowlog/pprof", slowlogProfile)
+	}
+
+	server := &http.Server{Addr: cfg.Listen, Handler: w}
+	defer server.Close()
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	if gateway != nil {
+		gatewayServer := &http.Server{Addr: cfg.FcgiGatewayListen, Handler: w.access.Wrap(gateway)}
+		defer gatewayServer.Close()
+		go func() {
+			errCh <- gatewayServer.ListenAndServe()
+		}()
+	}
+
+	done := ctx.Done()
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				stopFpm(log, fpmProcess, fpmExitCodeCh)
+				return 1, err
+			}
+		case <-done:
+			done = nil
+			signalCh <- syscall.SIGTERM
+		case exitCode := <-fpmExitCodeCh:
+			return exitCode, nil
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package wrapper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/code-tool/docker-fpm-wrapper/internal/enrich"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/phpfpm"
+	"github.com/code-tool/docker-fpm-wrapper/pkg/redact"
+)
+
+func TestDefaultOptions(t *testing.T) {
+	opts := DefaultOptions()
+
+	assert.Equal(t, "auto", opts.LogEncoder)
+	assert.Equal(t, "/metrics", opts.MetricsPath)
+	assert.Equal(t, 5*time.Second, opts.FpmStatusPoll)
+	assert.Equal(t, []string{"1m", "5m", "15m"}, opts.FpmSlowlogWindows)
+	assert.Equal(t, []string{"127.0.0.0/8", "::1"}, opts.HTTPAllow)
+	assert.Equal(t, 16*1024, opts.LineBufferSize)
+}
+
+func TestWrapperHandle(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LogEncoder = "json"
+
+	w, err := New(opts, io.Discard)
+	require.NoError(t, err)
+
+	w.Handle("/custom", http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(rw, "ok")
+	}))
+
+	for _, tt := range []struct {
+		path       string
+		remoteAddr string
+		code       int
+	}{
+		{"/custom", "127.0.0.1:1234", http.StatusOK},
+		{"/custom", "192.0.2.1:1234", http.StatusForbidden},
+		{"/loglevel", "192.0.2.1:1234", http.StatusForbidden},
+		{"/metrics", "192.0.2.1:1234", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+
+		w.ServeHTTP(rec, req)
+		assert.Equal(t, tt.code, rec.Code, "%s from %s", tt.path, tt.remoteAddr)
+	}
+}
+
+const testSlowlog = `[24-May-2022 09:37:47]  [pool www] pid 4219
+script_filename = /var/www/app/index.php
+[0x00007f177cf8ddb8] sleep() /var/www/app/src/Controller.php:12
+[0x00007f177cf8dc48] handle() /var/www/app/index.php:3
+
+`
+
+func serve(w *Wrapper, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestWrapperOwnRegistry(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LogEncoder = "json"
+	opts.FpmSlowlogReport = 0
+
+	for i := 0; i < 2; i++ {
+		w, err := New(opts, io.Discard)
+		require.NoError(t, err)
+
+		var topDuringParse int
+		w.AddSlowlogSink(sinkFunc(func() { topDuringParse = serve(w, "/slowlog/top").Code }))
+
+		for j := 0; j < 2; j++ {
+			err = w.ParseSlowlog(context.Background(), strings.NewReader(testSlowlog), false)
+			require.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, http.StatusOK, topDuringParse)
+		}
+
+		assert.Equal(t, http.StatusNotFound, serve(w, "/slowlog/top").Code)
+
+		rec := serve(w, "/metrics")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "go_goroutines")
+	}
+}
+
+type sinkFunc func()
+
+func (f sinkFunc) Add(phpfpm.SlowlogEntry) {
+	f()
+}
+
+func TestStopFpm(t *testing.T) {
+	// extra args make "sh -c script" of the fpm command line, the script exits by itself if SIGQUIT is ignored
+	fpmProcess := phpfpm.NewProcess(zap.NewNop(), "/bin/sh", "", io.Discard, io.Discard, 0, nil, "-c", "sleep 0.2")
+	require.NoError(t, fpmProcess.Start())
+
+	exitCodeCh := make(chan int, 1)
+	go func() {
+		exitCodeCh <- fpmProcess.Wait(make(chan error, 1))
+	}()
+
+	startedAt := time.Now()
+	stopFpm(zap.NewNop(), fpmProcess, exitCodeCh)
+
+	assert.Less(t, time.Since(startedAt), fpmStopTimeout)
+	assert.Error(t, fpmProcess.Signal(syscall.Signal(0)), "php-fpm has to be reaped")
+}
+
+func TestWriteSlowlogEntryRedacts(t *testing.T) {
+	opts := DefaultOptions()
+	opts.FpmSlowlogStripPrefix = "off"
+	redactor, err := redact.New(redact.Options{Detectors: []string{redact.DetectorEmail}})
+	require.NoError(t, err)
+	enc, err := createSlowlogEncoder(&opts)
+	require.NoError(t, err)
+	aggregator, err := createSlowlogAggregator(&opts)
+	require.NoError(t, err)
+
+	core, logs := observer.New(zap.DebugLevel)
+	writeSlowlogEntry(zap.New(core), enc, redactor, []SlowlogSink{aggregator}, enrich.NewNopEnricher(), nil,
+		phpfpm.SlowlogEntry{
+			CreatedAt:      time.Now(),
+			PoolName:       "www",
+			Pid:            42,
+			ScriptFilename: "/var/www/users/bob@example.com/index.php",
+			Stacktrace: []phpfpm.SlowlogTraceEntry{
+				{FunName: "sleep()", Path: "/var/www/users/bob@example.com/src/Controller.php", Line: 12},
+			},
+		},
+	)
+
+	rec := httptest.NewRecorder()
+	aggregator.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slowlog/top", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "bob@example.com")
+	assert.Contains(t, rec.Body.String(), "[REDACTED]/src/Controller.php")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "/var/www/users/[REDACTED]/index.php", logs.All()[0].ContextMap()["filename"])
+}